@@ -0,0 +1,79 @@
+// Package contracts คือ integration event สาธารณะที่ ordering และ inventory ส่งถึงกันผ่าน Kafka
+// schema ของ event แต่ละ version ต้องไม่ถูกแก้ไขแบบไม่ backward compatible
+// หากต้องเปลี่ยน ให้สร้าง version ใหม่ เช่น OrderPlacedV2 และส่งคู่กันจนกว่า consumer จะย้ายครบ
+package contracts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const TOPIC_ORDER_EVENT = "ORDER_EVENT"
+
+// Kafka headers ของ integration message
+const (
+	// HEADER_MESSAGE_ID คือ ID ของ message ซึ่งคงที่เมื่อส่งซ้ำ ให้ consumer ใช้ตัด message ซ้ำ
+	HEADER_MESSAGE_ID = "message-id"
+	// HEADER_EVENT_TYPE คือชื่อของ integration event เช่น OrderPlaced
+	HEADER_EVENT_TYPE = "event-type"
+	// HEADER_SCHEMA_VERSION คือ version ของ schema ของ integration event เช่น 1
+	HEADER_SCHEMA_VERSION = "schema-version"
+	// HEADER_AGGREGATE_ID คือ ID ของ aggregate ต้นทาง ซึ่งใช้เป็น key ของ message ด้วย
+	HEADER_AGGREGATE_ID = "aggregate-id"
+	// HEADER_EVENT_ID คือ ID ของ domain event ต้นทางใน event store
+	HEADER_EVENT_ID = "event-id"
+	// HEADER_EVENT_VERSION คือ version ของ aggregate หลัง domain event ต้นทาง
+	HEADER_EVENT_VERSION = "event-version"
+	// HEADER_OCCURRED_AT คือเวลาที่ domain event ต้นทางเกิดขึ้นในรูปแบบ RFC 3339
+	HEADER_OCCURRED_AT = "occurred-at"
+	// HEADER_CAUSATION_ID คือ message ID ของ message ที่ทำให้เกิด message นี้
+	HEADER_CAUSATION_ID = "causation-id"
+)
+
+var ErrUnknownIntegrationEvent = errors.New("unknown integration event")
+
+// IntegrationEvent คือ integration event ที่มีชื่อและ version ของ schema
+type IntegrationEvent interface {
+	EventType() string
+	SchemaVersion() int
+}
+
+var decoders = map[string]func(data []byte) (IntegrationEvent, error){}
+
+func register[T IntegrationEvent]() {
+	var event T
+	decoders[key(event.EventType(), event.SchemaVersion())] = func(data []byte) (IntegrationEvent, error) {
+		var decoded T
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	}
+}
+
+func key(eventType string, schemaVersion int) string {
+	return eventType + "/v" + strconv.Itoa(schemaVersion)
+}
+
+// Headers คืน header ที่ระบุชื่อและ version ของ schema ของ event
+func Headers(event IntegrationEvent) map[string]string {
+	return map[string]string{
+		HEADER_EVENT_TYPE:     event.EventType(),
+		HEADER_SCHEMA_VERSION: strconv.Itoa(event.SchemaVersion()),
+	}
+}
+
+// Decode แปลง payload เป็น integration event ตามชื่อและ version ของ schema ที่ระบุใน header
+func Decode(eventType string, schemaVersion string, data []byte) (IntegrationEvent, error) {
+	version, err := strconv.Atoi(schemaVersion)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid schema version %q of %s", ErrUnknownIntegrationEvent, schemaVersion, eventType)
+	}
+	decode, ok := decoders[key(eventType, version)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownIntegrationEvent, key(eventType, version))
+	}
+	return decode(data)
+}
@@ -0,0 +1,3 @@
+module github.com/Bass-Peerapon/eventsource-demo/contracts
+
+go 1.19
@@ -0,0 +1,55 @@
+package contracts
+
+import "time"
+
+const TOPIC_INVENTORY_EVENT = "INVENTORY_EVENT"
+
+func init() {
+	register[StockReservedV1]()
+	register[StockReservationFailedV1]()
+	register[StockReleasedV1]()
+}
+
+// ReservedLine คือจำนวนสินค้าที่ถูกจองให้ order
+type ReservedLine struct {
+	ItemID   string `json:"item_id"`
+	Quantity int    `json:"quantity"`
+}
+
+// StockShortage คือสินค้าที่มีไม่พอสำหรับการจอง
+// Requested คือจำนวนที่ต้องจองเพิ่มจากที่ order จองไว้แล้ว และ Available คือจำนวนที่ยังจองได้
+type StockShortage struct {
+	ItemID    string `json:"item_id"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// StockReservedV1 ถูกส่งเมื่อจองสินค้าตามรายการของ order สำเร็จ โดย Lines คือจำนวนที่จองอยู่ทั้งหมดของ order
+type StockReservedV1 struct {
+	OrderID    string         `json:"order_id"`
+	Lines      []ReservedLine `json:"lines"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+func (StockReservedV1) EventType() string  { return "StockReserved" }
+func (StockReservedV1) SchemaVersion() int { return 1 }
+
+// StockReservationFailedV1 ถูกส่งเมื่อสินค้าบางรายการมีไม่พอ ซึ่งการจองของ order จะไม่เปลี่ยนแปลง
+type StockReservationFailedV1 struct {
+	OrderID    string          `json:"order_id"`
+	Shortages  []StockShortage `json:"shortages"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
+
+func (StockReservationFailedV1) EventType() string  { return "StockReservationFailed" }
+func (StockReservationFailedV1) SchemaVersion() int { return 1 }
+
+// StockReleasedV1 ถูกส่งเมื่อคืนสินค้าที่จองไว้ทั้งหมดของ order ที่ถูกยกเลิก
+type StockReleasedV1 struct {
+	OrderID    string         `json:"order_id"`
+	Lines      []ReservedLine `json:"lines"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+func (StockReleasedV1) EventType() string  { return "StockReleased" }
+func (StockReleasedV1) SchemaVersion() int { return 1 }
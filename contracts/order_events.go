@@ -0,0 +1,71 @@
+package contracts
+
+import "time"
+
+func init() {
+	register[OrderCreatedV1]()
+	register[OrderUpdatedV1]()
+	register[OrderLineQuantityChangedV1]()
+	register[OrderPlacedV1]()
+	register[OrderCancelledV1]()
+}
+
+// OrderLine คือรายการสินค้าใน order
+type OrderLine struct {
+	ItemID   string `json:"item_id"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+// OrderCreatedV1 ถูกส่งเมื่อสร้าง order ใหม่ในสถานะร่าง
+type OrderCreatedV1 struct {
+	OrderID    string      `json:"order_id"`
+	Name       string      `json:"name"`
+	Lines      []OrderLine `json:"lines"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+func (OrderCreatedV1) EventType() string  { return "OrderCreated" }
+func (OrderCreatedV1) SchemaVersion() int { return 1 }
+
+// OrderUpdatedV1 ถูกส่งเมื่อแก้ไขชื่อหรือรายการสินค้าของ order ที่ยังเป็นร่าง
+type OrderUpdatedV1 struct {
+	OrderID    string      `json:"order_id"`
+	Name       string      `json:"name"`
+	Lines      []OrderLine `json:"lines"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+func (OrderUpdatedV1) EventType() string  { return "OrderUpdated" }
+func (OrderUpdatedV1) SchemaVersion() int { return 1 }
+
+// OrderLineQuantityChangedV1 ถูกส่งเมื่อเปลี่ยนจำนวนของสินค้าหนึ่งรายการใน order ที่ยังเป็นร่าง
+type OrderLineQuantityChangedV1 struct {
+	OrderID    string    `json:"order_id"`
+	ItemID     string    `json:"item_id"`
+	Quantity   int       `json:"quantity"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (OrderLineQuantityChangedV1) EventType() string  { return "OrderLineQuantityChanged" }
+func (OrderLineQuantityChangedV1) SchemaVersion() int { return 1 }
+
+// OrderPlacedV1 ถูกส่งเมื่อ order ถูก submit พร้อมรายการสินค้าที่ต้องจอง
+type OrderPlacedV1 struct {
+	OrderID    string      `json:"order_id"`
+	Lines      []OrderLine `json:"lines"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+func (OrderPlacedV1) EventType() string  { return "OrderPlaced" }
+func (OrderPlacedV1) SchemaVersion() int { return 1 }
+
+// OrderCancelledV1 ถูกส่งเมื่อ order ถูกยกเลิก
+type OrderCancelledV1 struct {
+	OrderID    string    `json:"order_id"`
+	Reason     string    `json:"reason"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+func (OrderCancelledV1) EventType() string  { return "OrderCancelled" }
+func (OrderCancelledV1) SchemaVersion() int { return 1 }
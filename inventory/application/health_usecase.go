@@ -0,0 +1,67 @@
+package application
+
+import (
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/infrastructure/messaging"
+)
+
+const (
+	HealthStatusUp   = "UP"
+	HealthStatusDown = "DOWN"
+)
+
+// HealthStatus คือผลการตรวจสอบสถานะของ service พร้อมสถานะของ consumer
+type HealthStatus struct {
+	Status   string                   `json:"status"`
+	Consumer messaging.ConsumerHealth `json:"consumer"`
+}
+
+// Up คืน true เมื่อ Status เป็น HealthStatusUp
+func (s HealthStatus) Up() bool {
+	return s.Status == HealthStatusUp
+}
+
+// HealthUsecase ตรวจสอบสถานะของ service จาก consumer
+// Liveness เป็น DOWN เมื่อ consumer หยุดทำงานแล้ว
+// Readiness เป็น UP เมื่อ consumer เป็นสมาชิกของ consumer group และ lag รวมของ topic ต้นทางไม่เกิน maxLag
+// lag ของ retry topic ไม่ถูกนำมาตรวจสอบเนื่องจาก message ใน retry topic ถูกหน่วงเวลาโดยตั้งใจ
+type HealthUsecase interface {
+	Liveness() HealthStatus
+	Readiness() HealthStatus
+}
+
+type healthUsecase struct {
+	consumer messaging.Consumer
+	maxLag   int64
+}
+
+// maxLag ที่น้อยกว่าหรือเท่ากับ 0 หมายถึงไม่ตรวจสอบ lag
+func NewHealthUsecase(consumer messaging.Consumer, maxLag int64) HealthUsecase {
+	return &healthUsecase{
+		consumer: consumer,
+		maxLag:   maxLag,
+	}
+}
+
+// Liveness implements HealthUsecase.
+func (u *healthUsecase) Liveness() HealthStatus {
+	health := u.consumer.Health()
+	return newHealthStatus(health.State != messaging.ConsumerStateStopped, health)
+}
+
+// Readiness implements HealthUsecase.
+func (u *healthUsecase) Readiness() HealthStatus {
+	health := u.consumer.Health()
+	ready := health.State == messaging.ConsumerStateRunning && (u.maxLag <= 0 || health.Lag <= u.maxLag)
+	return newHealthStatus(ready, health)
+}
+
+func newHealthStatus(up bool, health messaging.ConsumerHealth) HealthStatus {
+	status := HealthStatusDown
+	if up {
+		status = HealthStatusUp
+	}
+	return HealthStatus{
+		Status:   status,
+		Consumer: health,
+	}
+}
@@ -0,0 +1,226 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/domain/stock"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/helper"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/infrastructure/messaging"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// OrderEventHandler จองสินค้าเมื่อ order ถูก place และคืนสินค้าเมื่อ order ถูกยกเลิก ภายใน transaction ของ consumer
+// event ระหว่างที่ order ยังเป็น draft ไม่เกี่ยวกับการจองสินค้าจึงถูกข้าม
+// และบันทึกผลการจองลง outbox ใน transaction เดียวกัน ผลการจองจึงถูกส่งไปยัง TOPIC_INVENTORY_EVENT
+// โดย OutboxRelay หลังจาก consumer commit แล้วเท่านั้น
+type OrderEventHandler interface {
+	messaging.MessageHandler
+}
+
+type orderEventHandler struct {
+	stockRepository  stock.StockRepository
+	outboxRepository messaging.OutboxRepository
+}
+
+func NewOrderEventHandler(stockRepository stock.StockRepository, outboxRepository messaging.OutboxRepository) OrderEventHandler {
+	return &orderEventHandler{
+		stockRepository:  stockRepository,
+		outboxRepository: outboxRepository,
+	}
+}
+
+// HandleMessageTx implements messaging.MessageHandler.
+func (h *orderEventHandler) HandleMessageTx(ctx context.Context, tx *sqlx.Tx, message messaging.Message) error {
+	event, err := contracts.Decode(message.Headers[contracts.HEADER_EVENT_TYPE], message.Headers[contracts.HEADER_SCHEMA_VERSION], message.Value)
+	if err != nil {
+		// event ที่ inventory ไม่รู้จักไม่เกี่ยวกับการจองสินค้า
+		if errors.Is(err, contracts.ErrUnknownIntegrationEvent) {
+			helper.Println(fmt.Sprintf("Ignoring message: %v", err))
+			return nil
+		}
+		return err
+	}
+
+	switch event := event.(type) {
+	case contracts.OrderPlacedV1:
+		return h.reserve(ctx, tx, message, event.OrderID, linesToQuantities(event.Lines))
+	case contracts.OrderCancelledV1:
+		return h.release(ctx, tx, message, event.OrderID)
+	default:
+		helper.Println(fmt.Sprintf("Ignoring %s v%d", event.EventType(), event.SchemaVersion()))
+		return nil
+	}
+}
+
+// reserve ปรับจำนวนที่จองของ order ให้เท่ากับ desired โดยสินค้าที่จองไว้แต่ไม่อยู่ใน desired จะถูกคืนทั้งหมด
+// หากสินค้าไม่พอ การจองของ order จะไม่เปลี่ยนแปลงและส่ง StockReservationFailedV1 แทน
+func (h *orderEventHandler) reserve(ctx context.Context, tx *sqlx.Tx, message messaging.Message, orderID string, desired map[string]int) error {
+	current, err := h.stockRepository.GetReservationsForUpdateTx(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	for itemID := range current {
+		if _, ok := desired[itemID]; !ok {
+			desired[itemID] = 0
+		}
+	}
+
+	reservations, err := h.adjustReservations(ctx, tx, orderID, current, desired)
+	var insufficientStockErr *stock.InsufficientStockError
+	if errors.As(err, &insufficientStockErr) {
+		failed := contracts.StockReservationFailedV1{
+			OrderID:    orderID,
+			Shortages:  toStockShortages(insufficientStockErr.Shortages),
+			OccurredAt: time.Now(),
+		}
+		// stock และการจองไม่ถูกเปลี่ยนแปลง
+		return h.publish(ctx, tx, message, orderID, failed)
+	}
+	if err != nil {
+		return err
+	}
+
+	reserved := contracts.StockReservedV1{
+		OrderID:    orderID,
+		Lines:      toReservedLines(reservations),
+		OccurredAt: time.Now(),
+	}
+	return h.publish(ctx, tx, message, orderID, reserved)
+}
+
+// release คืนสินค้าที่จองไว้ทั้งหมดของ order
+func (h *orderEventHandler) release(ctx context.Context, tx *sqlx.Tx, message messaging.Message, orderID string) error {
+	current, err := h.stockRepository.GetReservationsForUpdateTx(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+	desired := make(map[string]int, len(current))
+	for itemID := range current {
+		desired[itemID] = 0
+	}
+	if _, err := h.adjustReservations(ctx, tx, orderID, current, desired); err != nil {
+		return err
+	}
+
+	released := contracts.StockReleasedV1{
+		OrderID:    orderID,
+		Lines:      toReservedLines(current),
+		OccurredAt: time.Now(),
+	}
+	return h.publish(ctx, tx, message, orderID, released)
+}
+
+// adjustReservations ล็อก stock ที่เกี่ยวข้อง ปรับการจองตาม desired และบันทึกการเปลี่ยนแปลงใน tx
+// คืนจำนวนที่จองทั้งหมดของ order หลังการปรับ
+func (h *orderEventHandler) adjustReservations(ctx context.Context, tx *sqlx.Tx, orderID string, current map[string]int, desired map[string]int) (map[string]int, error) {
+	itemIDs := make([]string, 0, len(desired))
+	for itemID := range desired {
+		itemIDs = append(itemIDs, itemID)
+	}
+	stocks, err := h.stockRepository.GetStocksForUpdateTx(ctx, tx, itemIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := stock.AdjustReservations(orderID, stocks, current, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	reservations := make(map[string]int, len(current))
+	for itemID, quantity := range current {
+		reservations[itemID] = quantity
+	}
+	for _, change := range changes {
+		if err := h.stockRepository.SaveReservationTx(ctx, tx, change); err != nil {
+			return nil, err
+		}
+		// stock ที่ไม่มีในคลังแล้วไม่ต้องคืนจำนวนที่จอง
+		if stock, ok := stocks[change.ItemID]; ok {
+			if err := h.stockRepository.SaveStockTx(ctx, tx, stock); err != nil {
+				return nil, err
+			}
+		}
+		if change.Quantity == 0 {
+			delete(reservations, change.ItemID)
+		} else {
+			reservations[change.ItemID] = change.Quantity
+		}
+	}
+	return reservations, nil
+}
+
+// publish บันทึกผลการจองของ order ลง outbox ใน tx โดยใช้ order ID เป็น key
+// message ID ถูกสร้างจาก message ID ของ event ต้นทาง จึงคงที่เมื่อ event ต้นทางถูกประมวลผลซ้ำ
+func (h *orderEventHandler) publish(ctx context.Context, tx *sqlx.Tx, cause messaging.Message, orderID string, event contracts.IntegrationEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", event.EventType(), err)
+	}
+
+	now := time.Now()
+	causationID := cause.Headers[contracts.HEADER_MESSAGE_ID]
+	messageID := resultMessageID(causationID, event)
+	headers := contracts.Headers(event)
+	headers[contracts.HEADER_MESSAGE_ID] = messageID.String()
+	headers[contracts.HEADER_AGGREGATE_ID] = orderID
+	headers[contracts.HEADER_CAUSATION_ID] = causationID
+	headers[contracts.HEADER_OCCURRED_AT] = now.UTC().Format(time.RFC3339Nano)
+
+	return h.outboxRepository.SaveTx(ctx, tx, []messaging.OutboxMessage{{
+		ID:        messageID,
+		Topic:     messaging.TOPIC_INVENTORY_EVENT,
+		Key:       orderID,
+		Payload:   payload,
+		Headers:   headers,
+		CreatedAt: now,
+	}})
+}
+
+func resultMessageID(causationID string, event contracts.IntegrationEvent) uuid.UUID {
+	causeID, err := uuid.FromString(causationID)
+	if err != nil {
+		// message ต้นทางไม่มี message ID จึงไม่สามารถสร้าง ID ที่คงที่ได้
+		return uuid.Must(uuid.NewV4())
+	}
+	return uuid.NewV5(causeID, contracts.TOPIC_INVENTORY_EVENT+"/"+event.EventType())
+}
+
+// linesToQuantities รวมจำนวนของสินค้าเดียวกันในรายการของ order
+func linesToQuantities(lines []contracts.OrderLine) map[string]int {
+	quantities := make(map[string]int, len(lines))
+	for _, line := range lines {
+		quantities[line.ItemID] += line.Quantity
+	}
+	return quantities
+}
+
+func toReservedLines(reservations map[string]int) []contracts.ReservedLine {
+	lines := make([]contracts.ReservedLine, 0, len(reservations))
+	for itemID, quantity := range reservations {
+		lines = append(lines, contracts.ReservedLine{ItemID: itemID, Quantity: quantity})
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].ItemID < lines[j].ItemID
+	})
+	return lines
+}
+
+func toStockShortages(shortages []stock.Shortage) []contracts.StockShortage {
+	result := make([]contracts.StockShortage, 0, len(shortages))
+	for _, shortage := range shortages {
+		result = append(result, contracts.StockShortage{
+			ItemID:    shortage.ItemID,
+			Requested: shortage.Requested,
+			Available: shortage.Available,
+		})
+	}
+	return result
+}
@@ -0,0 +1,141 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/helper"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/infrastructure/messaging"
+	"github.com/gofrs/uuid"
+)
+
+// DefaultOutboxBatchSize คือจำนวน message สูงสุดที่ relay ส่งต่อหนึ่ง transaction
+const DefaultOutboxBatchSize = 100
+
+// outboxPruneInterval คือช่วงเวลาที่ relay ลบ message ที่ถูกส่งแล้วเกิน retention
+const outboxPruneInterval = time.Hour
+
+// OutboxRelay ส่ง message ที่ยังไม่ถูกส่งในตาราง outbox ไปยัง message broker แบบ at-least-once หลังจาก consumer commit แล้ว
+// message ถูก mark ว่าส่งแล้วหลังจาก broker ตอบรับเท่านั้น หาก commit ล้มเหลว message จะถูกส่งซ้ำด้วย message ID เดิม
+// message ที่ถูกส่งแล้วนานกว่า retention จะถูกลบออกจาก outbox
+type OutboxRelay interface {
+	Run(ctx context.Context)
+	RelayPending(ctx context.Context) (int, error)
+}
+
+type outboxRelay struct {
+	outboxRepository messaging.OutboxRepository
+	messageBroker    messaging.MessageBroker
+	pollInterval     time.Duration
+	retention        time.Duration
+	batchSize        int
+}
+
+// NewOutboxRelay สร้าง OutboxRelay ที่ poll outbox ทุก pollInterval
+// retention เป็น 0 คือไม่ลบ message ที่ถูกส่งแล้ว
+func NewOutboxRelay(outboxRepository messaging.OutboxRepository, messageBroker messaging.MessageBroker, pollInterval time.Duration, retention time.Duration, batchSize int) OutboxRelay {
+	if batchSize <= 0 {
+		batchSize = DefaultOutboxBatchSize
+	}
+	return &outboxRelay{
+		outboxRepository: outboxRepository,
+		messageBroker:    messageBroker,
+		pollInterval:     pollInterval,
+		retention:        retention,
+		batchSize:        batchSize,
+	}
+}
+
+// Run implements OutboxRelay.
+// Run จะ block จนกว่า ctx ถูกยกเลิก โดย batch ที่เริ่มแล้วจะถูก commit ก่อนหยุด
+func (r *outboxRelay) Run(ctx context.Context) {
+	r.relayAll(ctx)
+	r.prune()
+
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	pruneTicker := time.NewTicker(outboxPruneInterval)
+	defer pruneTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.relayAll(ctx)
+		case <-pruneTicker.C:
+			r.prune()
+		}
+	}
+}
+
+// RelayPending implements OutboxRelay.
+// ส่ง message หนึ่ง batch และคืนจำนวน message ที่ส่งสำเร็จ
+func (r *outboxRelay) RelayPending(ctx context.Context) (int, error) {
+	tx, messages, err := r.outboxRepository.LockUnpublished(ctx, r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	published := make([]uuid.UUID, 0, len(messages))
+	var publishErr error
+	for _, message := range messages {
+		headers := make(map[string]string, len(message.Headers)+1)
+		for key, value := range message.Headers {
+			headers[key] = value
+		}
+		headers[contracts.HEADER_MESSAGE_ID] = message.ID.String()
+
+		if err := r.messageBroker.Publish(ctx, messaging.Message{
+			Topic:   message.Topic,
+			Key:     message.Key,
+			Value:   message.Payload,
+			Headers: headers,
+		}); err != nil {
+			// หยุดที่ message ที่ล้มเหลวเพื่อคงลำดับ และ mark เฉพาะ message ที่ส่งสำเร็จแล้ว
+			publishErr = fmt.Errorf("failed to publish outbox message %s: %w", message.ID, err)
+			break
+		}
+		published = append(published, message.ID)
+	}
+
+	if err := r.outboxRepository.MarkPublished(ctx, tx, published); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(published), publishErr
+}
+
+// relayAll ส่ง message ต่อเนื่องทีละ batch จนกว่าจะไม่มี message ค้าง หรือ ctx ถูกยกเลิก
+func (r *outboxRelay) relayAll(ctx context.Context) {
+	for ctx.Err() == nil {
+		// ไม่ใช้ ctx ของ service เพื่อให้ batch ปัจจุบัน commit ได้ครบก่อนหยุดเมื่อ shutdown
+		relayed, err := r.RelayPending(context.Background())
+		if err != nil {
+			helper.Println(fmt.Sprintf("Error relaying outbox messages: %v", err))
+			return
+		}
+		if relayed < r.batchSize {
+			return
+		}
+	}
+}
+
+// prune ลบ message ที่ถูกส่งแล้วนานกว่า retention
+func (r *outboxRelay) prune() {
+	if r.retention <= 0 {
+		return
+	}
+	deleted, err := r.outboxRepository.DeletePublishedBefore(context.Background(), time.Now().Add(-r.retention))
+	if err != nil {
+		helper.Println(fmt.Sprintf("Error pruning outbox messages: %v", err))
+		return
+	}
+	if deleted > 0 {
+		helper.Println(fmt.Sprintf("Pruned %d published outbox message(s)", deleted))
+	}
+}
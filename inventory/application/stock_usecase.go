@@ -0,0 +1,60 @@
+package application
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/domain/stock"
+)
+
+type StockUsecase interface {
+	GetStocks(ctx context.Context) ([]stock.Stock, error)
+	SetStock(ctx context.Context, itemID string, name string, onHand int) (*stock.Stock, error)
+}
+
+type stockUsecase struct {
+	stockRepository stock.StockRepository
+}
+
+func NewStockUsecase(stockRepository stock.StockRepository) StockUsecase {
+	return &stockUsecase{
+		stockRepository: stockRepository,
+	}
+}
+
+// GetStocks implements StockUsecase.
+func (u *stockUsecase) GetStocks(ctx context.Context) ([]stock.Stock, error) {
+	return u.stockRepository.GetStocks(ctx)
+}
+
+// SetStock implements StockUsecase.
+// สร้าง stock ใหม่หากยังไม่มี หรือกำหนดจำนวนในคลังของ stock เดิมโดยคงจำนวนที่ถูกจองไว้
+func (u *stockUsecase) SetStock(ctx context.Context, itemID string, name string, onHand int) (*stock.Stock, error) {
+	tx, err := u.stockRepository.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	s, err := u.stockRepository.GetStockForUpdateTx(ctx, tx, itemID)
+	if errors.Is(err, stock.ErrStockNotFound) {
+		s, err = &stock.Stock{ItemID: itemID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if name != "" {
+		s.Name = name
+	}
+	if err := s.SetOnHand(onHand); err != nil {
+		return nil, err
+	}
+	if err := u.stockRepository.SaveStockTx(ctx, tx, s); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -2,47 +2,246 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/application"
 	"github.com/Bass-Peerapon/eventsource-demo/inventory/infrastructure/messaging"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/infrastructure/persistence/postgres"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/interfaces"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/interfaces/api"
+	"github.com/golang-migrate/migrate/v4"
+	migrate_postgres "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/joho/godotenv/autoload"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	_ "github.com/lib/pq"
 )
 
 var (
+	INVENTORY_DB       = os.Getenv("INVENTORY_DB")
+	APP_PORT           = os.Getenv("APP_PORT")
 	KAFKA_BROKERS      = strings.Split(os.Getenv("KAFKA_BROKERS"), ",")
 	ORDER_EVENT_GROUP  = os.Getenv("ORDER_EVENT_GROUP")
 	ORDER_EVENT_TOPICS = strings.Split(os.Getenv("ORDER_EVENT_TOPICS"), ",")
+	// SHUTDOWN_TIMEOUT คือเวลาสูงสุดที่รอให้ HTTP request และ consumer ทำงานเสร็จเมื่อปิด service (ค่าเริ่มต้น 10s)
+	SHUTDOWN_TIMEOUT = os.Getenv("SHUTDOWN_TIMEOUT")
+	// KAFKA_TOPIC_PARTITIONS และ KAFKA_TOPIC_REPLICATION_FACTOR ใช้เมื่อสร้าง topic ที่ยังไม่มี (ค่าเริ่มต้น 1)
+	KAFKA_TOPIC_PARTITIONS         = os.Getenv("KAFKA_TOPIC_PARTITIONS")
+	KAFKA_TOPIC_REPLICATION_FACTOR = os.Getenv("KAFKA_TOPIC_REPLICATION_FACTOR")
+	// CONSUMER_MAX_ATTEMPTS คือจำนวนครั้งที่ทำซ้ำ handler ต่อหนึ่ง message (ค่าเริ่มต้น 3)
+	CONSUMER_MAX_ATTEMPTS = os.Getenv("CONSUMER_MAX_ATTEMPTS")
+	// CONSUMER_ERROR_ACTION คือ retry_topic (ค่าเริ่มต้น), skip หรือ stop เมื่อ handler ยังล้มเหลวหลังทำซ้ำครบ
+	CONSUMER_ERROR_ACTION = os.Getenv("CONSUMER_ERROR_ACTION")
+	// CONSUMER_RETRY_TOPIC_DELAYS คือเวลารอของแต่ละ retry topic คั่นด้วย comma (ค่าเริ่มต้น 10s,1m,10m)
+	CONSUMER_RETRY_TOPIC_DELAYS = os.Getenv("CONSUMER_RETRY_TOPIC_DELAYS")
+	// CONSUMER_READY_MAX_LAG คือ lag รวมสูงสุดของ topic ต้นทางที่ /readyz ยังถือว่าพร้อม ไม่รวม retry topic (ค่าเริ่มต้น 0 คือไม่ตรวจสอบ lag)
+	CONSUMER_READY_MAX_LAG = os.Getenv("CONSUMER_READY_MAX_LAG")
+	// OUTBOX_POLL_INTERVAL คือช่วงเวลาที่ outbox relay อ่าน message ที่ยังไม่ถูกส่ง (ค่าเริ่มต้น 1s)
+	OUTBOX_POLL_INTERVAL = os.Getenv("OUTBOX_POLL_INTERVAL")
+	// OUTBOX_RETENTION คือระยะเวลาที่เก็บ message ที่ถูกส่งแล้วไว้ใน outbox ก่อนลบ (ค่าเริ่มต้น 168h, 0 คือไม่ลบ)
+	OUTBOX_RETENTION = os.Getenv("OUTBOX_RETENTION")
 )
 
+func ConnectPostgres(conn string) *sqlx.DB {
+	db, err := sqlx.Connect("postgres", conn)
+	if err != nil {
+		panic(err)
+	}
+	return db
+}
+
 func main() {
-	// Connect to Kafka
-	kafkaConsumer := messaging.NewKafkaConsumer(KAFKA_BROKERS, ORDER_EVENT_GROUP, ORDER_EVENT_TOPICS)
+	inventoryDB := ConnectPostgres(INVENTORY_DB)
 
-	// Create a new context to control consumer shutdown
-	ctx, cancel := context.WithCancel(context.Background())
+	if err := runMigrations(inventoryDB, "file://migrate/inventory"); err != nil {
+		log.Fatal(err)
+	}
 
-	// Capture interrupt signals to gracefully shut down the consumer
-	go func() {
-		sigterm := make(chan os.Signal, 1)
-		signal.Notify(sigterm, syscall.SIGINT, syscall.SIGTERM)
-		<-sigterm
-		log.Println("Received shutdown signal, stopping consumer...")
-		cancel()
-	}()
+	stockRepository := postgres.NewStockRepository(inventoryDB)
+	inboxRepository := postgres.NewInboxRepository(inventoryDB)
+	outboxRepository := postgres.NewOutboxRepository(inventoryDB)
+
+	errorPolicy := messaging.DefaultErrorPolicy()
+	errorPolicy.MaxAttempts = intFromEnv("CONSUMER_MAX_ATTEMPTS", CONSUMER_MAX_ATTEMPTS, errorPolicy.MaxAttempts)
+	if CONSUMER_ERROR_ACTION != "" {
+		errorPolicy.OnExhausted = messaging.ErrorAction(CONSUMER_ERROR_ACTION)
+	}
+	if CONSUMER_RETRY_TOPIC_DELAYS != "" {
+		errorPolicy.RetryTopicDelays = nil
+		for _, delay := range strings.Split(CONSUMER_RETRY_TOPIC_DELAYS, ",") {
+			errorPolicy.RetryTopicDelays = append(errorPolicy.RetryTopicDelays, durationFromEnv("CONSUMER_RETRY_TOPIC_DELAYS", strings.TrimSpace(delay), 0))
+		}
+	}
+	if errorPolicy.OnExhausted != messaging.ErrorActionSkip && errorPolicy.OnExhausted != messaging.ErrorActionStop && errorPolicy.OnExhausted != messaging.ErrorActionRetryTopic {
+		log.Fatalf("invalid CONSUMER_ERROR_ACTION: %s", errorPolicy.OnExhausted)
+	}
+
+	// retry topic และ dead letter topic ของ topic ที่ consumer อ่าน
+	var retryTopics []string
+	if errorPolicy.OnExhausted == messaging.ErrorActionRetryTopic {
+		for _, topic := range ORDER_EVENT_TOPICS {
+			retryTopics = append(retryTopics, messaging.RetryTopics(topic, len(errorPolicy.RetryTopicDelays))...)
+			retryTopics = append(retryTopics, messaging.DeadLetterTopic(topic))
+		}
+	}
+
+	topicConfig := messaging.DefaultTopicConfig()
+	topicConfig.NumPartitions = int32(intFromEnv("KAFKA_TOPIC_PARTITIONS", KAFKA_TOPIC_PARTITIONS, int(topicConfig.NumPartitions)))
+	topicConfig.ReplicationFactor = int16(intFromEnv("KAFKA_TOPIC_REPLICATION_FACTOR", KAFKA_TOPIC_REPLICATION_FACTOR, int(topicConfig.ReplicationFactor)))
+	messageBroker := messaging.NewKafkaMessageBroker(KAFKA_BROKERS, topicConfig, retryTopics...)
+
+	orderEventHandler := application.NewOrderEventHandler(stockRepository, outboxRepository)
+	stockUsecase := application.NewStockUsecase(stockRepository)
+
+	// Capture interrupt signals to gracefully shut down the service
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// ทุก topic ใน ORDER_EVENT_TOPICS เป็น topic ของ integration event ของ order
+	messageRouter := messaging.NewMessageRouter()
+	for _, topic := range ORDER_EVENT_TOPICS {
+		for _, event := range []contracts.IntegrationEvent{
+			contracts.OrderCreatedV1{},
+			contracts.OrderUpdatedV1{},
+			contracts.OrderLineQuantityChangedV1{},
+			contracts.OrderPlacedV1{},
+			contracts.OrderCancelledV1{},
+		} {
+			messageRouter.Register(topic, event.EventType(), orderEventHandler)
+		}
+	}
 
 	// Start the consumer
+	kafkaConsumer := messaging.NewKafkaConsumer(KAFKA_BROKERS, ORDER_EVENT_GROUP, ORDER_EVENT_TOPICS, inboxRepository, messageRouter, errorPolicy, messageBroker)
 	if err := kafkaConsumer.StartConsumer(ctx); err != nil {
 		log.Fatalf("Error starting consumer: %v", err)
 	}
 
-	// Keep the application running to allow the consumer to process messages
+	outboxRelay := application.NewOutboxRelay(outboxRepository, messageBroker, durationFromEnv("OUTBOX_POLL_INTERVAL", OUTBOX_POLL_INTERVAL, time.Second), durationFromEnv("OUTBOX_RETENTION", OUTBOX_RETENTION, 7*24*time.Hour), application.DefaultOutboxBatchSize)
+	outboxRelayDone := make(chan struct{})
+	go func() {
+		outboxRelay.Run(ctx)
+		close(outboxRelayDone)
+	}()
+
+	// Errors ถูกปิดเมื่อ consumer หยุดทำงานแล้ว
+	consumerDone := make(chan struct{})
+	go func() {
+		for err := range kafkaConsumer.Errors() {
+			log.Printf("Consumer error: %v", err)
+		}
+		close(consumerDone)
+	}()
+
+	healthUsecase := application.NewHealthUsecase(kafkaConsumer, int64(intFromEnv("CONSUMER_READY_MAX_LAG", CONSUMER_READY_MAX_LAG, 0)))
+	stockHandler := api.NewStockHandler(stockUsecase)
+	healthHandler := api.NewHealthHandler(healthUsecase)
+
+	e := echo.New()
+	e.Use(middleware.Recover())
+	e.Use(middleware.Logger())
+
+	route := interfaces.NewRoute(e)
+	route.RegisterHTTPErrorHandler()
+	route.RegisterStockHandler(stockHandler)
+	route.RegisterHealthHandler(healthHandler)
+
+	go func() {
+		if err := e.Start(":" + APP_PORT); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server stopped: %v", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Received shutdown signal, shutting down inventory service...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), durationFromEnv("SHUTDOWN_TIMEOUT", SHUTDOWN_TIMEOUT, 10*time.Second))
+	defer cancel()
+
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down HTTP server: %v", err)
+	}
+
+	// consumer หยุดหลังจาก message ปัจจุบันถูกประมวลผลแล้ว ก่อนปิด message broker และ database
+	select {
+	case <-consumerDone:
+	case <-shutdownCtx.Done():
+		log.Println("Timed out waiting for consumer to stop")
+	}
+
+	// outbox relay หยุดหลังจาก batch ปัจจุบัน commit แล้ว ก่อนปิด message broker
 	select {
-	case <-ctx.Done():
-		log.Println("Shutting down application...")
-		time.Sleep(2 * time.Second) // Give time for cleanup
+	case <-outboxRelayDone:
+	case <-shutdownCtx.Done():
+		log.Println("Timed out waiting for outbox relay to stop")
+	}
+
+	if err := messageBroker.Close(); err != nil {
+		log.Printf("Error closing message broker: %v", err)
+	}
+	if err := inventoryDB.Close(); err != nil {
+		log.Printf("Error closing inventory database: %v", err)
+	}
+	log.Println("Inventory service stopped")
+}
+
+// durationFromEnv แปลงค่า environment variable เป็น time.Duration หรือคืนค่าเริ่มต้นหากไม่ได้กำหนด
+func durationFromEnv(name string, value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
 	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+	return duration
+}
+
+// intFromEnv แปลงค่า environment variable เป็น int หรือคืนค่าเริ่มต้นหากไม่ได้กำหนด
+func intFromEnv(name string, value string, defaultValue int) int {
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+	return n
+}
+
+func runMigrations(db *sqlx.DB, path string) error {
+	// Initialize migrate with a PostgreSQL database instance
+	driver, err := migrate_postgres.WithInstance(db.DB, &migrate_postgres.Config{})
+	if err != nil {
+		return err
+	}
+
+	m, err := migrate.NewWithDatabaseInstance(
+		path,       // Path to the migrations folder
+		"postgres", // Database name
+		driver,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Apply the migrations
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return err
+	}
+
+	log.Println("Migrations applied successfully")
+	return nil
 }
@@ -0,0 +1,45 @@
+// redrive ส่ง message ใน dead letter topic ของ inventory consumer กลับไปยัง topic ต้นทาง
+//
+//	redrive -topic ORDER_EVENT.dlq
+//
+// message ที่ถูกส่งกลับจะถูกประมวลผลใหม่โดย inventory service ตามลำดับ version ของ aggregate
+// message ถัดไปของ aggregate เดียวกันที่ถูกส่งตามไปยัง dead letter topic ต้องถูกส่งกลับด้วย
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/infrastructure/messaging"
+	_ "github.com/joho/godotenv/autoload"
+)
+
+var (
+	KAFKA_BROKERS     = strings.Split(os.Getenv("KAFKA_BROKERS"), ",")
+	ORDER_EVENT_GROUP = os.Getenv("ORDER_EVENT_GROUP")
+)
+
+func main() {
+	topic := flag.String("topic", messaging.DeadLetterTopic(contracts.TOPIC_ORDER_EVENT), "dead letter topic to redrive")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	messageBroker := messaging.NewKafkaMessageBroker(KAFKA_BROKERS, messaging.DefaultTopicConfig())
+	defer messageBroker.Close()
+
+	// ใช้ consumer group แยกจาก consumer หลัก เพื่อเก็บตำแหน่งที่ส่งกลับแล้วของ dead letter topic
+	redriver := messaging.NewDeadLetterRedriver(KAFKA_BROKERS, ORDER_EVENT_GROUP+".redrive", messageBroker)
+	redriven, err := redriver.Redrive(ctx, *topic)
+	if err != nil {
+		log.Fatalf("Redrove %d message(s) from %s before failing: %v", redriven, *topic, err)
+	}
+	log.Printf("Redrove %d message(s) from %s", redriven, *topic)
+}
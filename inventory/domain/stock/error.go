@@ -0,0 +1,39 @@
+package stock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrStockNotFound          = errors.New("stock not found")
+	ErrInsufficientStock      = errors.New("insufficient stock")
+	ErrOnHandLessThanZero     = errors.New("on hand is less than zero")
+	ErrOnHandLessThanReserved = errors.New("on hand is less than reserved")
+	ErrQuantityLessThanZero   = errors.New("quantity is less than zero")
+)
+
+// Shortage คือสินค้าที่มีจำนวนคงเหลือไม่พอสำหรับการจอง
+type Shortage struct {
+	ItemID    string `json:"item_id"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+}
+
+// InsufficientStockError คืนรายการสินค้าทั้งหมดที่จองไม่ได้ และเทียบได้กับ ErrInsufficientStock ด้วย errors.Is
+type InsufficientStockError struct {
+	Shortages []Shortage
+}
+
+func (e *InsufficientStockError) Error() string {
+	items := make([]string, 0, len(e.Shortages))
+	for _, shortage := range e.Shortages {
+		items = append(items, fmt.Sprintf("%s (requested %d, available %d)", shortage.ItemID, shortage.Requested, shortage.Available))
+	}
+	return fmt.Sprintf("%s: %s", ErrInsufficientStock, strings.Join(items, ", "))
+}
+
+func (e *InsufficientStockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
@@ -0,0 +1,19 @@
+package stock
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// StockRepository อ่านและบันทึก stock และ reservation
+// เมธอดที่ลงท้ายด้วย Tx ทำงานภายใน transaction ที่เปิดจาก Begin และล็อกแถวที่อ่านไว้จน commit
+type StockRepository interface {
+	Begin(ctx context.Context) (*sqlx.Tx, error)
+	GetStocks(ctx context.Context) ([]Stock, error)
+	GetStockForUpdateTx(ctx context.Context, tx *sqlx.Tx, itemID string) (*Stock, error)
+	GetStocksForUpdateTx(ctx context.Context, tx *sqlx.Tx, itemIDs []string) (map[string]*Stock, error)
+	SaveStockTx(ctx context.Context, tx *sqlx.Tx, stock *Stock) error
+	GetReservationsForUpdateTx(ctx context.Context, tx *sqlx.Tx, orderID string) (map[string]int, error)
+	SaveReservationTx(ctx context.Context, tx *sqlx.Tx, reservation Reservation) error
+}
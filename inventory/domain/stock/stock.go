@@ -0,0 +1,81 @@
+package stock
+
+import "sort"
+
+// Stock คือจำนวนสินค้าในคลังของสินค้าหนึ่งรายการ
+// Reserved คือจำนวนที่ถูกจองให้ order แล้ว จำนวนที่จองได้คือ OnHand - Reserved
+type Stock struct {
+	ItemID   string `json:"item_id"`
+	Name     string `json:"name"`
+	OnHand   int    `json:"on_hand"`
+	Reserved int    `json:"reserved"`
+}
+
+// Reservation คือจำนวนสินค้าที่ถูกจองให้ order หนึ่ง
+type Reservation struct {
+	OrderID  string `json:"order_id"`
+	ItemID   string `json:"item_id"`
+	Quantity int    `json:"quantity"`
+}
+
+func (s *Stock) Available() int {
+	return s.OnHand - s.Reserved
+}
+
+// SetOnHand กำหนดจำนวนสินค้าในคลัง ซึ่งต้องไม่น้อยกว่าจำนวนที่ถูกจองอยู่
+func (s *Stock) SetOnHand(onHand int) error {
+	if onHand < 0 {
+		return ErrOnHandLessThanZero
+	}
+	if onHand < s.Reserved {
+		return ErrOnHandLessThanReserved
+	}
+	s.OnHand = onHand
+	return nil
+}
+
+// AdjustReservations ปรับจำนวนที่จองของ order ให้เท่ากับ desired ต่อสินค้า
+// สินค้าที่จำนวนเพิ่มขึ้นจะถูกจองเพิ่ม และสินค้าที่จำนวนลดลงจะถูกคืน
+// หากมีสินค้าใดไม่พอจะคืน *InsufficientStockError ที่ระบุสินค้าทั้งหมดที่ไม่พอ โดย caller ต้องไม่บันทึกการเปลี่ยนแปลง
+// คืน reservation ของสินค้าที่เปลี่ยนแปลง ซึ่ง Quantity เป็น 0 หมายถึงการจองถูกยกเลิก
+func AdjustReservations(orderID string, stocks map[string]*Stock, current map[string]int, desired map[string]int) ([]Reservation, error) {
+	itemIDs := make([]string, 0, len(desired))
+	for itemID, quantity := range desired {
+		if quantity < 0 {
+			return nil, ErrQuantityLessThanZero
+		}
+		itemIDs = append(itemIDs, itemID)
+	}
+	sort.Strings(itemIDs)
+
+	var shortages []Shortage
+	changes := make([]Reservation, 0, len(itemIDs))
+	for _, itemID := range itemIDs {
+		delta := desired[itemID] - current[itemID]
+		if delta == 0 {
+			continue
+		}
+
+		stock, ok := stocks[itemID]
+		if !ok && delta < 0 {
+			// สินค้าที่ไม่มีในคลังแล้วไม่ต้องคืนจำนวนที่จอง แต่การจองของ order ยังต้องถูกปรับ
+			changes = append(changes, Reservation{OrderID: orderID, ItemID: itemID, Quantity: desired[itemID]})
+			continue
+		}
+		if !ok {
+			shortages = append(shortages, Shortage{ItemID: itemID, Requested: delta})
+			continue
+		}
+		if delta > 0 && delta > stock.Available() {
+			shortages = append(shortages, Shortage{ItemID: itemID, Requested: delta, Available: stock.Available()})
+			continue
+		}
+		stock.Reserved += delta
+		changes = append(changes, Reservation{OrderID: orderID, ItemID: itemID, Quantity: desired[itemID]})
+	}
+
+	if len(shortages) > 0 {
+		return nil, &InsufficientStockError{Shortages: shortages}
+	}
+	return changes, nil
+}
@@ -0,0 +1,127 @@
+package stock
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestAdjustReservations(t *testing.T) {
+	tests := []struct {
+		name          string
+		stocks        map[string]*Stock
+		current       map[string]int
+		desired       map[string]int
+		wantChanges   []Reservation
+		wantReserved  map[string]int
+		wantShortages []Shortage
+		wantErr       error
+	}{
+		{
+			name:         "reserve new item",
+			stocks:       map[string]*Stock{"A": {ItemID: "A", OnHand: 10}},
+			current:      map[string]int{},
+			desired:      map[string]int{"A": 3},
+			wantChanges:  []Reservation{{OrderID: "order-1", ItemID: "A", Quantity: 3}},
+			wantReserved: map[string]int{"A": 3},
+		},
+		{
+			name:         "increase amount reserves the difference",
+			stocks:       map[string]*Stock{"A": {ItemID: "A", OnHand: 10, Reserved: 5}},
+			current:      map[string]int{"A": 2},
+			desired:      map[string]int{"A": 4},
+			wantChanges:  []Reservation{{OrderID: "order-1", ItemID: "A", Quantity: 4}},
+			wantReserved: map[string]int{"A": 7},
+		},
+		{
+			name:         "decrease amount releases stock",
+			stocks:       map[string]*Stock{"A": {ItemID: "A", OnHand: 10, Reserved: 6}},
+			current:      map[string]int{"A": 5},
+			desired:      map[string]int{"A": 2},
+			wantChanges:  []Reservation{{OrderID: "order-1", ItemID: "A", Quantity: 2}},
+			wantReserved: map[string]int{"A": 3},
+		},
+		{
+			name:         "zero amount cancels reservation",
+			stocks:       map[string]*Stock{"A": {ItemID: "A", OnHand: 10, Reserved: 4}},
+			current:      map[string]int{"A": 4},
+			desired:      map[string]int{"A": 0},
+			wantChanges:  []Reservation{{OrderID: "order-1", ItemID: "A", Quantity: 0}},
+			wantReserved: map[string]int{"A": 0},
+		},
+		{
+			name:         "unchanged amount is not a change",
+			stocks:       map[string]*Stock{"A": {ItemID: "A", OnHand: 10, Reserved: 4}},
+			current:      map[string]int{"A": 4},
+			desired:      map[string]int{"A": 4},
+			wantChanges:  []Reservation{},
+			wantReserved: map[string]int{"A": 4},
+		},
+		{
+			name:          "not enough available stock is a shortage",
+			stocks:        map[string]*Stock{"A": {ItemID: "A", OnHand: 10, Reserved: 8}},
+			current:       map[string]int{},
+			desired:       map[string]int{"A": 3},
+			wantShortages: []Shortage{{ItemID: "A", Requested: 3, Available: 2}},
+			wantErr:       ErrInsufficientStock,
+		},
+		{
+			name:          "missing stock row is a shortage",
+			stocks:        map[string]*Stock{},
+			current:       map[string]int{},
+			desired:       map[string]int{"A": 1},
+			wantShortages: []Shortage{{ItemID: "A", Requested: 1, Available: 0}},
+			wantErr:       ErrInsufficientStock,
+		},
+		{
+			name:         "releasing missing stock row still cancels reservation",
+			stocks:       map[string]*Stock{"A": {ItemID: "A", OnHand: 10, Reserved: 2}},
+			current:      map[string]int{"A": 2, "B": 3},
+			desired:      map[string]int{"A": 0, "B": 0},
+			wantChanges:  []Reservation{{OrderID: "order-1", ItemID: "A", Quantity: 0}, {OrderID: "order-1", ItemID: "B", Quantity: 0}},
+			wantReserved: map[string]int{"A": 0},
+		},
+		{
+			name: "all shortages are reported",
+			stocks: map[string]*Stock{
+				"A": {ItemID: "A", OnHand: 1},
+				"B": {ItemID: "B", OnHand: 10},
+			},
+			current:       map[string]int{},
+			desired:       map[string]int{"A": 2, "B": 1, "C": 1},
+			wantShortages: []Shortage{{ItemID: "A", Requested: 2, Available: 1}, {ItemID: "C", Requested: 1, Available: 0}},
+			wantErr:       ErrInsufficientStock,
+		},
+		{
+			name:    "negative amount is rejected",
+			stocks:  map[string]*Stock{"A": {ItemID: "A", OnHand: 10}},
+			current: map[string]int{},
+			desired: map[string]int{"A": -1},
+			wantErr: ErrQuantityLessThanZero,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := AdjustReservations("order-1", tt.stocks, tt.current, tt.desired)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AdjustReservations() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				var insufficientStockErr *InsufficientStockError
+				if errors.As(err, &insufficientStockErr) && !reflect.DeepEqual(insufficientStockErr.Shortages, tt.wantShortages) {
+					t.Errorf("AdjustReservations() shortages = %v, want %v", insufficientStockErr.Shortages, tt.wantShortages)
+				}
+				return
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("AdjustReservations() = %v, want %v", changes, tt.wantChanges)
+			}
+			for itemID, reserved := range tt.wantReserved {
+				if got := tt.stocks[itemID].Reserved; got != reserved {
+					t.Errorf("stock %s reserved = %d, want %d", itemID, got, reserved)
+				}
+			}
+		})
+	}
+}
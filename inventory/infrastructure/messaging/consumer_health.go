@@ -0,0 +1,151 @@
+package messaging
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type ConsumerState string
+
+const (
+	// ConsumerStateStarting คือสถานะก่อนเข้าร่วม consumer group ครั้งแรก
+	ConsumerStateStarting ConsumerState = "STARTING"
+	// ConsumerStateRunning คือสถานะที่เป็นสมาชิกของ consumer group และอยู่ใน session
+	ConsumerStateRunning ConsumerState = "RUNNING"
+	// ConsumerStateRebalancing คือสถานะระหว่าง session จบและรอเข้าร่วม session ถัดไป
+	ConsumerStateRebalancing ConsumerState = "REBALANCING"
+	// ConsumerStateStopped คือสถานะหลังจาก consumer หยุดทำงานแล้ว
+	ConsumerStateStopped ConsumerState = "STOPPED"
+)
+
+// PartitionLag คือตำแหน่งของ consumer ใน partition ที่ได้รับมอบหมาย
+// Offset คือ offset ถัดไปที่จะประมวลผล และ Lag คือจำนวน message ที่ยังไม่ถูกประมวลผลจนถึง HighWaterMark
+// Retry เป็น true เมื่อ partition เป็นของ retry topic
+type PartitionLag struct {
+	Topic         string `json:"topic"`
+	Partition     int32  `json:"partition"`
+	Offset        int64  `json:"offset"`
+	HighWaterMark int64  `json:"high_water_mark"`
+	Lag           int64  `json:"lag"`
+	Retry         bool   `json:"retry"`
+}
+
+// ConsumerHealth คือสถานะการเป็นสมาชิกของ consumer group และ lag ของทุก partition ที่ได้รับมอบหมาย
+// Lag คือ lag รวมของ topic ต้นทาง และ RetryLag คือ lag รวมของ retry topic ซึ่งถูกหน่วงเวลาโดยตั้งใจ
+type ConsumerHealth struct {
+	Group        string         `json:"group"`
+	State        ConsumerState  `json:"state"`
+	MemberID     string         `json:"member_id,omitempty"`
+	GenerationID int32          `json:"generation_id,omitempty"`
+	Partitions   []PartitionLag `json:"partitions"`
+	Lag          int64          `json:"lag"`
+	RetryLag     int64          `json:"retry_lag"`
+	LastError    string         `json:"last_error,omitempty"`
+	LastErrorAt  *time.Time     `json:"last_error_at,omitempty"`
+}
+
+type topicPartition struct {
+	topic     string
+	partition int32
+}
+
+// consumerStatus เก็บสถานะของ consumer ซึ่งถูกอัปเดตจาก goroutine ของ sarama และอ่านจาก HTTP handler
+type consumerStatus struct {
+	mu           sync.RWMutex
+	health       ConsumerHealth
+	partitions   map[topicPartition]PartitionLag
+	sourceTopics map[string]bool
+}
+
+// sourceTopics คือ topic ต้นทางที่ consumer อ่าน topic อื่นถือเป็น retry topic
+func newConsumerStatus(group string, sourceTopics []string) *consumerStatus {
+	status := &consumerStatus{
+		health: ConsumerHealth{
+			Group: group,
+			State: ConsumerStateStarting,
+		},
+		partitions:   make(map[topicPartition]PartitionLag),
+		sourceTopics: make(map[string]bool, len(sourceTopics)),
+	}
+	for _, topic := range sourceTopics {
+		status.sourceTopics[topic] = true
+	}
+	return status
+}
+
+func (s *consumerStatus) startSession(memberID string, generationID int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.health.State = ConsumerStateRunning
+	s.health.MemberID = memberID
+	s.health.GenerationID = generationID
+	s.partitions = make(map[topicPartition]PartitionLag)
+}
+
+// endSession ล้าง partition ของ session ที่จบแล้ว เนื่องจาก session ถัดไปอาจได้รับมอบหมาย partition ต่างออกไป
+func (s *consumerStatus) endSession() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.health.State != ConsumerStateStopped {
+		s.health.State = ConsumerStateRebalancing
+	}
+	s.partitions = make(map[topicPartition]PartitionLag)
+}
+
+func (s *consumerStatus) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.health.State = ConsumerStateStopped
+	s.health.MemberID = ""
+	s.health.GenerationID = 0
+	s.partitions = make(map[topicPartition]PartitionLag)
+}
+
+// trackPosition บันทึก offset ถัดไปที่จะประมวลผลและ high water mark ล่าสุดของ partition
+func (s *consumerStatus) trackPosition(topic string, partition int32, offset int64, highWaterMark int64) {
+	lag := highWaterMark - offset
+	if lag < 0 {
+		lag = 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partitions[topicPartition{topic: topic, partition: partition}] = PartitionLag{
+		Topic:         topic,
+		Partition:     partition,
+		Offset:        offset,
+		HighWaterMark: highWaterMark,
+		Lag:           lag,
+		Retry:         !s.sourceTopics[topic],
+	}
+}
+
+func (s *consumerStatus) recordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	s.health.LastError = err.Error()
+	s.health.LastErrorAt = &now
+}
+
+func (s *consumerStatus) snapshot() ConsumerHealth {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	health := s.health
+	health.Partitions = make([]PartitionLag, 0, len(s.partitions))
+	for _, partition := range s.partitions {
+		health.Partitions = append(health.Partitions, partition)
+		if partition.Retry {
+			health.RetryLag += partition.Lag
+		} else {
+			health.Lag += partition.Lag
+		}
+	}
+	sort.Slice(health.Partitions, func(i, j int) bool {
+		if health.Partitions[i].Topic != health.Partitions[j].Topic {
+			return health.Partitions[i].Topic < health.Partitions[j].Topic
+		}
+		return health.Partitions[i].Partition < health.Partitions[j].Partition
+	})
+	return health
+}
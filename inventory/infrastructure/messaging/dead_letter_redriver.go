@@ -0,0 +1,161 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/IBM/sarama"
+)
+
+// DeadLetterRedriver ส่ง message ใน dead letter topic กลับไปยัง topic ต้นทางเพื่อให้ consumer ประมวลผลอีกครั้ง
+// Redrive อ่านเฉพาะ message ที่มีอยู่ใน dead letter topic ณ ตอนเริ่ม และคืนจำนวน message ที่ถูกส่งกลับ
+// ตำแหน่งที่ส่งกลับแล้วถูก commit ใน consumer group ของ redriver จึงไม่ถูกส่งกลับซ้ำเมื่อเรียกอีกครั้ง
+type DeadLetterRedriver interface {
+	Redrive(ctx context.Context, topic string) (int, error)
+}
+
+type deadLetterRedriver struct {
+	brokers       []string
+	group         string
+	messageBroker MessageBroker
+}
+
+func NewDeadLetterRedriver(brokers []string, group string, messageBroker MessageBroker) DeadLetterRedriver {
+	return &deadLetterRedriver{
+		brokers:       brokers,
+		group:         group,
+		messageBroker: messageBroker,
+	}
+}
+
+// Redrive implements DeadLetterRedriver.
+func (r *deadLetterRedriver) Redrive(ctx context.Context, topic string) (int, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_1_0_0 // Make sure the Kafka version matches your Kafka cluster version
+	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+
+	client, err := sarama.NewClient(r.brokers, config)
+	if err != nil {
+		return 0, fmt.Errorf("error creating client: %w", err)
+	}
+	defer client.Close()
+
+	// หยุดที่ high water mark ณ ตอนเริ่ม เพื่อไม่รอ message ใหม่ที่เข้ามาระหว่างส่งกลับ
+	partitions, err := client.Partitions(topic)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list partitions of %s: %w", topic, err)
+	}
+	highWaterMarks := make(map[int32]int64, len(partitions))
+	for _, partition := range partitions {
+		offset, err := client.GetOffset(topic, partition, sarama.OffsetNewest)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read offset of %s/%d: %w", topic, partition, err)
+		}
+		highWaterMarks[partition] = offset
+	}
+
+	consumerGroup, err := sarama.NewConsumerGroupFromClient(r.group, client)
+	if err != nil {
+		return 0, fmt.Errorf("error creating consumer group client: %w", err)
+	}
+	defer consumerGroup.Close()
+
+	// ยกเลิก redriveCtx เมื่อทุก partition ถูกส่งกลับถึง high water mark แล้ว
+	redriveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	handler := &redriveHandler{
+		messageBroker:  r.messageBroker,
+		highWaterMarks: highWaterMarks,
+		done:           make(map[int32]bool, len(partitions)),
+		cancel:         cancel,
+	}
+	for redriveCtx.Err() == nil && handler.Err() == nil {
+		if err := consumerGroup.Consume(redriveCtx, []string{topic}, handler); err != nil {
+			return int(atomic.LoadInt64(&handler.redriven)), err
+		}
+	}
+	if err := handler.Err(); err != nil {
+		return int(atomic.LoadInt64(&handler.redriven)), err
+	}
+	return int(atomic.LoadInt64(&handler.redriven)), ctx.Err()
+}
+
+type redriveHandler struct {
+	messageBroker  MessageBroker
+	highWaterMarks map[int32]int64
+	redriven       int64
+	cancel         context.CancelFunc
+
+	mu   sync.Mutex
+	done map[int32]bool
+	err  error
+}
+
+// Err คืน error แรกที่เกิดขึ้นระหว่างส่ง message กลับ
+func (h *redriveHandler) Err() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.err
+}
+
+// Setup is run at the beginning of a new session, before ConsumeClaim
+func (h *redriveHandler) Setup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
+func (h *redriveHandler) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+// ConsumeClaim ส่ง message ของ partition กลับไปยัง topic ต้นทางจนถึง high water mark ณ ตอนเริ่ม
+// partition ที่ส่งกลับครบแล้วจะรอจน session จบ เพราะ session ของ sarama จบทันทีเมื่อ ConsumeClaim ใดคืนค่า
+func (h *redriveHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	end := h.highWaterMarks[claim.Partition()]
+	reached := claim.InitialOffset() >= end
+	if !reached {
+		for message := range claim.Messages() {
+			redrive := redriveMessage(toMessage(message))
+			if err := h.messageBroker.Publish(session.Context(), redrive); err != nil {
+				h.setErr(fmt.Errorf("failed to redrive message at offset %d of %s/%d: %w", message.Offset, message.Topic, message.Partition, err))
+				return nil
+			}
+			log.Printf("Redrove message at offset %d of %s/%d to %s", message.Offset, message.Topic, message.Partition, redrive.Topic)
+			atomic.AddInt64(&h.redriven, 1)
+			session.MarkMessage(message, "")
+			if message.Offset+1 >= end {
+				reached = true
+				break
+			}
+		}
+	}
+	// session ถูกยกเลิกก่อนส่งกลับครบ partition นี้จะถูกส่งกลับต่อใน session ถัดไป
+	if !reached {
+		return nil
+	}
+
+	h.markDone(claim.Partition())
+	<-session.Context().Done()
+	return nil
+}
+
+func (h *redriveHandler) markDone(partition int32) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.done[partition] = true
+	if len(h.done) == len(h.highWaterMarks) {
+		h.cancel()
+	}
+}
+
+func (h *redriveHandler) setErr(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.err == nil {
+		h.err = err
+	}
+	h.cancel()
+}
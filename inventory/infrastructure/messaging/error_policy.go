@@ -0,0 +1,99 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ErrorAction กำหนดสิ่งที่ consumer ทำเมื่อ handler ยังล้มเหลวหลังทำซ้ำครบตาม ErrorPolicy
+type ErrorAction string
+
+const (
+	// ErrorActionSkip บันทึก log และ mark offset ของ message เพื่อประมวลผล message ถัดไป
+	ErrorActionSkip ErrorAction = "skip"
+	// ErrorActionStop ไม่ mark offset และจบ session ของ consumer
+	// message จะถูกส่งมาใหม่เมื่อ consumer เข้าร่วม group อีกครั้ง ทำให้ partition หยุดรอจนกว่า handler จะสำเร็จ
+	ErrorActionStop ErrorAction = "stop"
+	// ErrorActionRetryTopic ส่ง message ไปยัง retry topic ถัดไปตาม RetryTopicDelays แล้ว mark offset
+	// message ที่ล้มเหลวครบทุก retry topic จะถูกส่งไปยัง dead letter topic
+	// ระหว่างที่ message ของ aggregate ค้างอยู่ใน retry topic หรือ dead letter topic
+	// message ถัดไปของ aggregate เดียวกันจะถูกส่งตามไปใน retry topic เพื่อคงลำดับ จนกว่า message ที่ค้างอยู่จะสำเร็จ
+	ErrorActionRetryTopic ErrorAction = "retry_topic"
+)
+
+// ErrorPolicy ใช้ทำซ้ำ handler ที่ล้มเหลวโดยรอแบบ exponential backoff พร้อม jitter
+// และกำหนด OnExhausted เมื่อครบ MaxAttempts หรือเจอ error ที่ไม่ควรทำซ้ำ
+type ErrorPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	OnExhausted    ErrorAction
+	// RetryTopicDelays คือเวลารอก่อนประมวลผล message ในแต่ละ retry topic ใช้เมื่อ OnExhausted เป็น ErrorActionRetryTopic
+	RetryTopicDelays []time.Duration
+	// Retryable ตัดสินว่า error ใดควรทำซ้ำ ถ้าไม่กำหนดจะทำซ้ำทุก error
+	Retryable func(err error) bool
+}
+
+func DefaultErrorPolicy() ErrorPolicy {
+	return ErrorPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		OnExhausted:    ErrorActionRetryTopic,
+		RetryTopicDelays: []time.Duration{
+			10 * time.Second,
+			1 * time.Minute,
+			10 * time.Minute,
+		},
+	}
+}
+
+// Do เรียก fn จนกว่าจะสำเร็จ เจอ error ที่ไม่ควรทำซ้ำ หรือครบ MaxAttempts และคืน error ครั้งล่าสุด
+func (p ErrorPolicy) Do(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !p.isRetryable(err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p ErrorPolicy) isRetryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return true
+}
+
+// backoff คำนวณเวลารอก่อนทำซ้ำครั้งถัดไปแบบ equal jitter
+// ใช้สูตรเดียวกับ RetryPolicy.backoff ของ ordering service หากแก้ไขต้องแก้ทั้งสองที่ให้ตรงกัน
+func (p ErrorPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		backoff *= p.Multiplier
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	half := int64(backoff / 2)
+	if half <= 0 {
+		return time.Duration(backoff)
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
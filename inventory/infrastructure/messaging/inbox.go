@@ -0,0 +1,63 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
+	"github.com/jmoiron/sqlx"
+)
+
+var (
+	// ErrMissingInboxHeaders คือ error เมื่อ message ไม่มี header ที่ใช้ตัด message ซ้ำ
+	ErrMissingInboxHeaders = errors.New("message has no aggregate-id, event-id or event-version header")
+	// ErrAggregateParked คือ error เมื่อ aggregate ของ message มี message ก่อนหน้าค้างอยู่ใน retry topic หรือ dead letter topic
+	ErrAggregateParked = errors.New("aggregate has an earlier message in the retry path")
+)
+
+// InboxMessage คือ message ที่ถูกประมวลผลแล้ว ระบุด้วย event ID และ aggregate ID ของ domain event ต้นทาง
+type InboxMessage struct {
+	AggregateID string
+	EventID     int64
+	MessageID   string
+	Topic       string
+	EventType   string
+	Version     int
+	// Skipped เป็น true เมื่อ message เก่ากว่า version ล่าสุดของ aggregate ที่ประมวลผลแล้วจึงไม่ถูกส่งให้ handler
+	Skipped bool
+}
+
+// NewInboxMessage อ่าน InboxMessage จาก header ของ message
+func NewInboxMessage(message Message) (InboxMessage, error) {
+	aggregateID := message.Headers[contracts.HEADER_AGGREGATE_ID]
+	eventID, eventIDErr := strconv.ParseInt(message.Headers[contracts.HEADER_EVENT_ID], 10, 64)
+	version, versionErr := strconv.Atoi(message.Headers[contracts.HEADER_EVENT_VERSION])
+	if aggregateID == "" || eventIDErr != nil || versionErr != nil {
+		return InboxMessage{}, fmt.Errorf("%w: topic = %s, key = %s", ErrMissingInboxHeaders, message.Topic, message.Key)
+	}
+	return InboxMessage{
+		AggregateID: aggregateID,
+		EventID:     eventID,
+		MessageID:   message.Headers[contracts.HEADER_MESSAGE_ID],
+		Topic:       message.Topic,
+		EventType:   message.Headers[contracts.HEADER_EVENT_TYPE],
+		Version:     version,
+	}, nil
+}
+
+// InboxRepository บันทึก message ที่ประมวลผลแล้วใน transaction เดียวกับการเปลี่ยนแปลงของ handler
+// SaveTx คืน false หาก message ถูกบันทึกไว้แล้ว
+// GetAggregateVersionForUpdateTx ล็อกและคืน version ล่าสุดที่ประมวลผลแล้วของ aggregate หรือ 0 หากยังไม่เคยประมวลผล
+// ParkTx บันทึกว่า message ของ aggregate อยู่ใน retry topic หรือ dead letter topic และ UnparkTx ลบออกเมื่อประมวลผลแล้ว
+// GetFirstParkedEventTx คืน event ID ของ message ที่ version ต่ำที่สุดที่ยังค้างอยู่ของ aggregate และ false หากไม่มี
+type InboxRepository interface {
+	Begin(ctx context.Context) (*sqlx.Tx, error)
+	SaveTx(ctx context.Context, tx *sqlx.Tx, message InboxMessage) (bool, error)
+	GetAggregateVersionForUpdateTx(ctx context.Context, tx *sqlx.Tx, aggregateID string) (int, error)
+	SaveAggregateVersionTx(ctx context.Context, tx *sqlx.Tx, aggregateID string, version int) error
+	ParkTx(ctx context.Context, tx *sqlx.Tx, message InboxMessage) error
+	UnparkTx(ctx context.Context, tx *sqlx.Tx, aggregateID string, eventID int64) error
+	GetFirstParkedEventTx(ctx context.Context, tx *sqlx.Tx, aggregateID string) (int64, bool, error)
+}
@@ -2,82 +2,350 @@ package messaging
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
 	"github.com/Bass-Peerapon/eventsource-demo/inventory/helper"
 	"github.com/IBM/sarama"
 )
 
+// consumerRestartBackoff คือเวลารอก่อนเข้าร่วม consumer group ใหม่เมื่อ session ล้มเหลว
+const consumerRestartBackoff = 2 * time.Second
+
+// lagRefreshInterval คือช่วงเวลาที่อัปเดต lag จาก high water mark ล่าสุด แม้ partition ไม่มี message ใหม่
+const lagRefreshInterval = 5 * time.Second
+
+// Consumer อ่าน message จาก topic ใน consumer group
+// StartConsumer สร้าง consumer group และอ่าน message ใน background จนกว่า ctx ถูกยกเลิก
+// Errors คืน error ของ consumer group และ handler ที่หยุด partition ซึ่งถูกปิดเมื่อ consumer หยุดทำงานแล้ว
+// Health คืนสถานะการเป็นสมาชิกของ consumer group และ lag ของแต่ละ partition
 type Consumer interface {
 	StartConsumer(ctx context.Context) error
+	Errors() <-chan error
+	Health() ConsumerHealth
 }
 
 type kafkaConsumer struct {
-	brokers []string
-	group   string
-	topics  []string
-	ready   chan bool
+	brokers         []string
+	group           string
+	topics          []string
+	inboxRepository InboxRepository
+	router          MessageRouter
+	errorPolicy     ErrorPolicy
+	messageBroker   MessageBroker
+	status          *consumerStatus
+	errors          chan error
 }
 
-func NewKafkaConsumer(brokers []string, group string, topics []string) Consumer {
+// messageBroker ใช้ส่ง message ที่ล้มเหลวไปยัง retry topic และ dead letter topic เมื่อ errorPolicy เป็น ErrorActionRetryTopic
+func NewKafkaConsumer(brokers []string, group string, topics []string, inboxRepository InboxRepository, router MessageRouter, errorPolicy ErrorPolicy, messageBroker MessageBroker) Consumer {
 	// NewKafkaConsumer initializes a new Kafka consumer for the specified brokers, group, and topics
 	return &kafkaConsumer{
-		brokers: brokers,
-		group:   group,
-		topics:  topics,
-		ready:   make(chan bool),
+		brokers:         brokers,
+		group:           group,
+		topics:          topics,
+		inboxRepository: inboxRepository,
+		router:          router,
+		errorPolicy:     errorPolicy,
+		messageBroker:   messageBroker,
+		status:          newConsumerStatus(group, topics),
+		errors:          make(chan error, 16),
 	}
 }
 
 // StartConsumer starts the Kafka consumer to consume messages from the given topics
+// ไม่รอให้เข้าร่วม consumer group สำเร็จ สถานะการเข้าร่วมดูได้จาก Health
+// คืน error หาก topic ใดไม่มี handler ลงทะเบียนกับ router เนื่องจาก message ทั้งหมดของ topic นั้นจะถูกข้าม
 func (kc *kafkaConsumer) StartConsumer(ctx context.Context) error {
+	for _, topic := range kc.topics {
+		if !kc.router.HasRoutes(topic) {
+			return fmt.Errorf("no message handler is registered for topic %s", topic)
+		}
+	}
+
 	config := sarama.NewConfig()
 	config.Version = sarama.V2_1_0_0 // Make sure the Kafka version matches your Kafka cluster version
 	config.Consumer.Group.Rebalance.Strategy = sarama.NewBalanceStrategyRoundRobin()
 	config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	config.Consumer.Return.Errors = true
 
 	consumerGroup, err := sarama.NewConsumerGroup(kc.brokers, kc.group, config)
 	if err != nil {
 		return fmt.Errorf("error creating consumer group client: %w", err)
 	}
 
+	// channel errors ของ consumer group ถูกปิดเมื่อ consumer group ถูกปิด จึงปิด kc.errors ต่อจากนั้น
+	go func() {
+		for err := range consumerGroup.Errors() {
+			kc.reportError(err)
+		}
+		kc.status.stop()
+		close(kc.errors)
+	}()
+
 	go func() {
+		defer func() {
+			// kc.errors อาจถูกปิดแล้วหลัง consumer group ถูกปิด จึงบันทึกเพียง log
+			if err := consumerGroup.Close(); err != nil {
+				log.Printf("Error closing consumer group: %v", err)
+			}
+		}()
 		for {
-			if err := consumerGroup.Consume(ctx, kc.topics, kc); err != nil {
-				log.Printf("Error from consumer: %v", err)
+			if err := consumerGroup.Consume(ctx, kc.subscribedTopics(), kc); err != nil {
+				if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+					return
+				}
+				kc.reportError(fmt.Errorf("error from consumer: %w", err))
+				select {
+				case <-ctx.Done():
+				case <-time.After(consumerRestartBackoff):
+				}
 			}
 			// Check if context was canceled, signaling the consumer to stop
 			if ctx.Err() != nil {
 				return
 			}
-			kc.ready = make(chan bool)
 		}
 	}()
 
-	<-kc.ready // Wait till the consumer has been set up
-	log.Println("Sarama consumer up and running!...")
+	log.Println("Sarama consumer started")
 	return nil
 }
 
+// Errors implements Consumer.
+func (kc *kafkaConsumer) Errors() <-chan error {
+	return kc.errors
+}
+
+// Health implements Consumer.
+func (kc *kafkaConsumer) Health() ConsumerHealth {
+	return kc.status.snapshot()
+}
+
+// reportError บันทึก error ล่าสุดและส่งต่อไปยัง Errors โดยไม่รอ หากไม่มีผู้อ่านทันจะทิ้ง error นั้น
+func (kc *kafkaConsumer) reportError(err error) {
+	kc.status.recordError(err)
+	select {
+	case kc.errors <- err:
+	default:
+		log.Printf("Dropping consumer error: %v", err)
+	}
+}
+
+// subscribedTopics คืน topic ที่ consumer อ่าน รวมถึง retry topic เมื่อ errorPolicy เป็น ErrorActionRetryTopic
+func (kc *kafkaConsumer) subscribedTopics() []string {
+	if kc.errorPolicy.OnExhausted != ErrorActionRetryTopic {
+		return kc.topics
+	}
+	topics := make([]string, 0, len(kc.topics)*(len(kc.errorPolicy.RetryTopicDelays)+1))
+	for _, topic := range kc.topics {
+		topics = append(topics, topic)
+		topics = append(topics, RetryTopics(topic, len(kc.errorPolicy.RetryTopicDelays))...)
+	}
+	return topics
+}
+
 // Setup is run at the beginning of a new session, before ConsumeClaim
-func (kc *kafkaConsumer) Setup(sarama.ConsumerGroupSession) error {
-	// Mark the consumer as ready
-	close(kc.ready)
+func (kc *kafkaConsumer) Setup(session sarama.ConsumerGroupSession) error {
+	kc.status.startSession(session.MemberID(), session.GenerationID())
+	helper.Println(fmt.Sprintf("Consumer joined group %s: member = %s, generation = %d, claims = %v", kc.group, session.MemberID(), session.GenerationID(), session.Claims()))
 	return nil
 }
 
 // Cleanup is run at the end of a session, once all ConsumeClaim goroutines have exited
 func (kc *kafkaConsumer) Cleanup(sarama.ConsumerGroupSession) error {
+	kc.status.endSession()
 	return nil
 }
 
 // ConsumeClaim must start a consumer loop of ConsumerGroupClaim's Messages().
+// offset ของ message ถูก mark หลังจาก handler สำเร็จ หรือเมื่อ message ที่ล้มเหลวถูกข้ามหรือส่งไปยัง retry topic แล้ว
+// lag ถูกอัปเดตหลังประมวลผลแต่ละ message และทุก lagRefreshInterval จาก high water mark ที่ sarama ได้รับจากการ fetch
 func (kc *kafkaConsumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	// Note: Do not use defer here as it will slow down the processing
-	for message := range claim.Messages() {
-		helper.Println(fmt.Sprintf("Message claimed: value = %s, timestamp = %v, topic = %s", string(message.Value), message.Timestamp, message.Topic))
-		session.MarkMessage(message, "")
+	offset := claim.InitialOffset()
+	kc.status.trackPosition(claim.Topic(), claim.Partition(), offset, claim.HighWaterMarkOffset())
+	ticker := time.NewTicker(lagRefreshInterval)
+	defer ticker.Stop()
+	// Note: Do not use defer inside the loop as it will slow down the processing
+	for {
+		select {
+		case <-session.Context().Done():
+			return nil
+		case <-ticker.C:
+			kc.status.trackPosition(claim.Topic(), claim.Partition(), offset, claim.HighWaterMarkOffset())
+		case message, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			helper.Println(fmt.Sprintf("Message claimed: value = %s, timestamp = %v, topic = %s", string(message.Value), message.Timestamp, message.Topic))
+			msg := toMessage(message)
+			if err := waitUntilRetryDelay(session.Context(), msg); err != nil {
+				// session ถูกยกเลิกระหว่างรอ message จะถูกส่งมาใหม่ใน session ถัดไป
+				return nil
+			}
+			if err := kc.handleMessage(session.Context(), msg); err != nil {
+				// session ถูกยกเลิกระหว่างทำซ้ำ message จะถูกส่งมาใหม่ใน session ถัดไป
+				if session.Context().Err() != nil {
+					return nil
+				}
+				switch kc.errorPolicy.OnExhausted {
+				case ErrorActionSkip:
+					log.Printf("Skipping message at offset %d of %s/%d: %v", message.Offset, message.Topic, message.Partition, err)
+				case ErrorActionRetryTopic:
+					// message ของ aggregate ที่ถูกพักไว้แล้วถูกบันทึกไว้ตั้งแต่ตอนตรวจสอบ
+					if !errors.Is(err, ErrAggregateParked) {
+						if err := kc.park(session.Context(), msg); err != nil {
+							return fmt.Errorf("failed to park message at offset %d of %s/%d: %w", message.Offset, message.Topic, message.Partition, err)
+						}
+					}
+					retry := nextRetryMessage(msg, err, kc.errorPolicy.RetryTopicDelays, time.Now())
+					if err := kc.messageBroker.Publish(session.Context(), retry); err != nil {
+						return fmt.Errorf("failed to publish message at offset %d of %s/%d to %s: %w", message.Offset, message.Topic, message.Partition, retry.Topic, err)
+					}
+					log.Printf("Moved message at offset %d of %s/%d to %s: %v", message.Offset, message.Topic, message.Partition, retry.Topic, err)
+				default:
+					return fmt.Errorf("stopped at offset %d of %s/%d: %w", message.Offset, message.Topic, message.Partition, err)
+				}
+			}
+			session.MarkMessage(message, "")
+			offset = message.Offset + 1
+			kc.status.trackPosition(message.Topic, message.Partition, offset, claim.HighWaterMarkOffset())
+		}
+	}
+}
+
+// handleMessage ส่ง message ให้ handler ที่ลงทะเบียนกับ router และทำซ้ำตาม errorPolicy
+// message จาก retry topic ถูกส่งให้ handler ของ topic ต้นทาง และ message ที่ไม่มี handler จะถูกข้าม
+func (kc *kafkaConsumer) handleMessage(ctx context.Context, message Message) error {
+	message.Topic = OriginalTopic(message)
+	handler, ok := kc.router.Route(message)
+	if !ok {
+		log.Printf("No handler for %s %s, skipping message at offset %d of partition %d", message.Topic, message.Headers[contracts.HEADER_EVENT_TYPE], message.Offset, message.Partition)
+		return nil
+	}
+	// aggregate ที่ถูกพักไว้จะยังถูกพักจนกว่า message ก่อนหน้าจะสำเร็จ จึงไม่ทำซ้ำ
+	var parkedErr error
+	err := kc.errorPolicy.Do(ctx, func() error {
+		err := kc.processMessage(ctx, handler, message)
+		if errors.Is(err, ErrAggregateParked) {
+			parkedErr = err
+			return nil
+		}
+		return err
+	})
+	if parkedErr != nil {
+		return parkedErr
+	}
+	return err
+}
+
+// park บันทึกว่า message ถูกส่งไปยัง retry topic เพื่อให้ message ถัดไปของ aggregate เดียวกันตามไปใน retry topic
+// message ที่ไม่มี header ของ event ต้นทางไม่ถูกตัดซ้ำจึงไม่ถูกพัก และ message ถัดไปของ aggregate นั้นอาจถูกประมวลผลก่อน
+func (kc *kafkaConsumer) park(ctx context.Context, message Message) error {
+	inboxMessage, err := NewInboxMessage(message)
+	if err != nil {
+		log.Printf("Cannot park message at offset %d of partition %d of %s, later messages of its aggregate may be processed out of order: %v", message.Offset, message.Partition, message.Topic, err)
+		return nil
+	}
+	tx, err := kc.inboxRepository.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := kc.inboxRepository.ParkTx(ctx, tx, inboxMessage); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// processMessage ส่ง message ให้ handler และบันทึกลง inbox ใน transaction เดียวกัน
+// message ที่อยู่ใน inbox แล้วจะถูกข้าม และ message ที่ version ไม่ใหม่กว่า version ล่าสุดของ aggregate
+// จะถูกบันทึกว่าข้ามโดยไม่ส่งให้ handler เพื่อไม่ให้ event เก่าทับผลของ event ที่ใหม่กว่า
+// version ที่ข้ามไปเกิดได้จาก domain event ที่ไม่ถูกส่งเป็น integration event จึงไม่ถือว่าผิดลำดับ
+// หาก aggregate มี message ที่ถูกพักอยู่ใน retry topic หรือ dead letter topic จะประมวลผลเฉพาะ message ที่ถูกพักซึ่ง version ต่ำที่สุด
+// message อื่นของ aggregate นั้นจะถูกพักและคืน ErrAggregateParked เพื่อส่งตามไปใน retry topic โดยคงลำดับของ aggregate
+// ตาม decideParkAction
+func (kc *kafkaConsumer) processMessage(ctx context.Context, handler MessageHandler, message Message) error {
+	tx, err := kc.inboxRepository.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	inboxMessage, err := NewInboxMessage(message)
+	if err != nil {
+		// message ที่ส่งก่อนมี header ของ event ต้นทางไม่สามารถตัดซ้ำได้
+		log.Printf("Handling message without deduplication: %v", err)
+		if err := handler.HandleMessageTx(ctx, tx, message); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	// ล็อก aggregate เพื่อให้ message ของ aggregate เดียวกันถูกประมวลผลทีละ message
+	lastVersion, err := kc.inboxRepository.GetAggregateVersionForUpdateTx(ctx, tx, inboxMessage.AggregateID)
+	if err != nil {
+		return fmt.Errorf("failed to read inbox version of aggregate %s: %w", inboxMessage.AggregateID, err)
+	}
+
+	parkedEventID, parked, err := kc.inboxRepository.GetFirstParkedEventTx(ctx, tx, inboxMessage.AggregateID)
+	if err != nil {
+		return err
+	}
+	switch decideParkAction(kc.errorPolicy.OnExhausted, parkedEventID, parked, inboxMessage.EventID) {
+	case parkActionPark:
+		if err := kc.inboxRepository.ParkTx(ctx, tx, inboxMessage); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: aggregate = %s, event = %d, parked event = %d", ErrAggregateParked, inboxMessage.AggregateID, inboxMessage.EventID, parkedEventID)
+	case parkActionUnpark:
+		// message ที่ถูกพักถูกนำออกเมื่อ transaction นี้ commit เท่านั้น หาก handler ล้มเหลวจะยังถูกพักอยู่
+		if err := kc.inboxRepository.UnparkTx(ctx, tx, inboxMessage.AggregateID, inboxMessage.EventID); err != nil {
+			return err
+		}
+	}
+	inboxMessage.Skipped = inboxMessage.Version <= lastVersion
+
+	saved, err := kc.inboxRepository.SaveTx(ctx, tx, inboxMessage)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		helper.Println(fmt.Sprintf("Skipping duplicate message: aggregate = %s, event = %d", inboxMessage.AggregateID, inboxMessage.EventID))
+		return tx.Commit()
+	}
+	if inboxMessage.Skipped {
+		helper.Println(fmt.Sprintf("Skipping out-of-order message: aggregate = %s, version = %d, last version = %d", inboxMessage.AggregateID, inboxMessage.Version, lastVersion))
+		return tx.Commit()
+	}
+
+	if err := handler.HandleMessageTx(ctx, tx, message); err != nil {
+		return err
+	}
+	if err := kc.inboxRepository.SaveAggregateVersionTx(ctx, tx, inboxMessage.AggregateID, inboxMessage.Version); err != nil {
+		return fmt.Errorf("failed to save inbox version of aggregate %s: %w", inboxMessage.AggregateID, err)
+	}
+	return tx.Commit()
+}
+
+// toMessage แปลง sarama.ConsumerMessage เป็น Message
+func toMessage(message *sarama.ConsumerMessage) Message {
+	headers := make(map[string]string, len(message.Headers))
+	for _, h := range message.Headers {
+		headers[string(h.Key)] = string(h.Value)
+	}
+	return Message{
+		Topic:     message.Topic,
+		Partition: message.Partition,
+		Offset:    message.Offset,
+		Key:       string(message.Key),
+		Value:     message.Value,
+		Headers:   headers,
 	}
-	return nil
 }
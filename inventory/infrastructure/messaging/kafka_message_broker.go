@@ -0,0 +1,128 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
+	"github.com/IBM/sarama"
+)
+
+const TOPIC_INVENTORY_EVENT = contracts.TOPIC_INVENTORY_EVENT
+
+// TopicConfig กำหนดจำนวน partition และ replication factor ของ topic ที่ถูกสร้างเมื่อยังไม่มี
+type TopicConfig struct {
+	NumPartitions     int32
+	ReplicationFactor int16
+}
+
+func DefaultTopicConfig() TopicConfig {
+	return TopicConfig{
+		NumPartitions:     1,
+		ReplicationFactor: 1,
+	}
+}
+
+type MessageBroker interface {
+	Publish(ctx context.Context, message Message) error
+	Close() error
+}
+
+type kafkaMessageBroker struct {
+	producer sarama.SyncProducer
+}
+
+// Publish implements MessageBroker.
+func (k *kafkaMessageBroker) Publish(ctx context.Context, message Message) error {
+	// sarama.SyncProducer ไม่รองรับ context จึงตรวจสอบก่อนส่งเพื่อไม่ส่ง message หลังถูกยกเลิก
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers))
+	for key, value := range message.Headers {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
+	_, _, err := k.producer.SendMessage(&sarama.ProducerMessage{
+		Topic:   message.Topic,
+		Key:     sarama.StringEncoder(message.Key),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
+	})
+	return err
+}
+
+// Close implements MessageBroker.
+func (k *kafkaMessageBroker) Close() error {
+	return k.producer.Close()
+}
+
+// topics คือ topic อื่นนอกจาก TOPIC_INVENTORY_EVENT ที่ต้องสร้างหากยังไม่มี เช่น retry topic และ dead letter topic
+func NewKafkaMessageBroker(brokers []string, topicConfig TopicConfig, topics ...string) MessageBroker {
+	// Create admin client
+	admin, err := newKafkaAdmin(brokers)
+	if err != nil {
+		panic(err)
+	}
+	defer admin.Close()
+
+	// Create topic if it does not exist
+	for _, topic := range append([]string{TOPIC_INVENTORY_EVENT}, topics...) {
+		err = createTopicIfNotExists(admin, topic, topicConfig)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// Create producer
+	producer, err := newKafkaProducer(brokers)
+	if err != nil {
+		panic(err)
+	}
+
+	return &kafkaMessageBroker{
+		producer: producer,
+	}
+}
+
+func newKafkaProducer(brokers []string) (sarama.SyncProducer, error) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	config.Producer.RequiredAcks = sarama.WaitForAll
+	config.Producer.Retry.Max = 5
+	// message ที่มี key เดียวกัน (order ID) จะถูกส่งไปยัง partition เดียวกันเสมอ
+	config.Producer.Partitioner = sarama.NewHashPartitioner
+
+	return sarama.NewSyncProducer(brokers, config)
+}
+
+func newKafkaAdmin(brokers []string) (sarama.ClusterAdmin, error) {
+	config := sarama.NewConfig()
+	config.Version = sarama.V2_1_0_0 // Make sure the Kafka version matches your Kafka cluster version
+
+	return sarama.NewClusterAdmin(brokers, config)
+}
+
+func createTopicIfNotExists(admin sarama.ClusterAdmin, topic string, topicConfig TopicConfig) error {
+	topics, err := admin.ListTopics()
+	if err != nil {
+		return fmt.Errorf("failed to list topics: %w", err)
+	}
+
+	// Check if the topic already exists
+	if _, ok := topics[topic]; ok {
+		fmt.Printf("Topic %s already exists\n", topic)
+		return nil
+	}
+
+	err = admin.CreateTopic(topic, &sarama.TopicDetail{
+		NumPartitions:     topicConfig.NumPartitions,
+		ReplicationFactor: topicConfig.ReplicationFactor,
+		ConfigEntries:     make(map[string]*string),
+	}, false)
+	if err != nil {
+		return fmt.Errorf("failed to create topic %s: %w", topic, err)
+	}
+
+	fmt.Printf("Topic %s created successfully\n", topic)
+	return nil
+}
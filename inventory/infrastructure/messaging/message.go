@@ -0,0 +1,24 @@
+package messaging
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Message คือ message ที่รับจากหรือส่งไปยัง message broker
+// Partition และ Offset มีค่าเฉพาะ message ที่รับจาก consumer และไม่ถูกใช้เมื่อส่ง
+type Message struct {
+	Topic     string
+	Partition int32
+	Offset    int64
+	Key       string
+	Value     []byte
+	Headers   map[string]string
+}
+
+// MessageHandler ประมวลผล message ที่ consumer ได้รับ
+// การเปลี่ยนแปลงต้องทำผ่าน tx ซึ่ง consumer ใช้บันทึก inbox และ commit หลัง handler คืนค่าสำเร็จ
+type MessageHandler interface {
+	HandleMessageTx(ctx context.Context, tx *sqlx.Tx, message Message) error
+}
@@ -0,0 +1,29 @@
+package messaging
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// OutboxMessage คือ message ที่ถูกบันทึกใน transaction เดียวกับการเปลี่ยนแปลงของ handler และรอส่งไปยัง message broker
+type OutboxMessage struct {
+	ID        uuid.UUID
+	Topic     string
+	Key       string
+	Payload   []byte
+	Headers   map[string]string
+	CreatedAt time.Time
+}
+
+// OutboxRepository บันทึก OutboxMessage ร่วมกับ transaction ของ consumer และอ่าน message ที่ยังไม่ถูกส่ง
+// LockUnpublished อ่าน message ที่ยังไม่ถูกส่งไม่เกิน limit รายการเมื่อได้ lock ของ outbox และคืนรายการว่างหาก relay อื่นถือ lock อยู่
+// DeletePublishedBefore ลบ message ที่ถูกส่งก่อน before และคืนจำนวน message ที่ถูกลบ
+type OutboxRepository interface {
+	SaveTx(ctx context.Context, tx *sqlx.Tx, messages []OutboxMessage) error
+	LockUnpublished(ctx context.Context, limit int) (*sqlx.Tx, []OutboxMessage, error)
+	MarkPublished(ctx context.Context, tx *sqlx.Tx, ids []uuid.UUID) error
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,165 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// HEADER_RETRY_ATTEMPT คือลำดับของ retry topic ที่ message ถูกส่งไป เริ่มจาก 1
+	HEADER_RETRY_ATTEMPT = "retry-attempt"
+	// HEADER_RETRY_NOT_BEFORE คือเวลาที่เร็วที่สุดที่ message ใน retry topic จะถูกประมวลผลในรูปแบบ RFC 3339
+	HEADER_RETRY_NOT_BEFORE = "retry-not-before"
+	// HEADER_ORIGINAL_TOPIC, HEADER_ORIGINAL_PARTITION และ HEADER_ORIGINAL_OFFSET คือตำแหน่งของ message ใน topic ต้นทาง
+	HEADER_ORIGINAL_TOPIC     = "original-topic"
+	HEADER_ORIGINAL_PARTITION = "original-partition"
+	HEADER_ORIGINAL_OFFSET    = "original-offset"
+	// HEADER_ERROR คือ error ครั้งล่าสุดของ handler
+	HEADER_ERROR = "error"
+	// HEADER_FAILED_AT คือเวลาที่ message ถูกส่งไปยัง dead letter topic ในรูปแบบ RFC 3339
+	HEADER_FAILED_AT = "failed-at"
+)
+
+// retryHeaders คือ header ที่ถูกเพิ่มเมื่อ message ถูกส่งไปยัง retry topic หรือ dead letter topic
+var retryHeaders = []string{
+	HEADER_RETRY_ATTEMPT,
+	HEADER_RETRY_NOT_BEFORE,
+	HEADER_ORIGINAL_TOPIC,
+	HEADER_ORIGINAL_PARTITION,
+	HEADER_ORIGINAL_OFFSET,
+	HEADER_ERROR,
+	HEADER_FAILED_AT,
+}
+
+// RetryTopic คืนชื่อ retry topic ลำดับที่ attempt ของ topic เช่น ORDER_EVENT.retry.1
+func RetryTopic(topic string, attempt int) string {
+	return fmt.Sprintf("%s.retry.%d", topic, attempt)
+}
+
+// DeadLetterTopic คืนชื่อ dead letter topic ของ topic เช่น ORDER_EVENT.dlq
+func DeadLetterTopic(topic string) string {
+	return topic + ".dlq"
+}
+
+// RetryTopics คืนชื่อ retry topic ทุกลำดับของ topic
+func RetryTopics(topic string, tiers int) []string {
+	topics := make([]string, 0, tiers)
+	for attempt := 1; attempt <= tiers; attempt++ {
+		topics = append(topics, RetryTopic(topic, attempt))
+	}
+	return topics
+}
+
+// OriginalTopic คืน topic ต้นทางของ message ที่ถูกส่งไปยัง retry topic หรือ dead letter topic
+func OriginalTopic(message Message) string {
+	if topic := message.Headers[HEADER_ORIGINAL_TOPIC]; topic != "" {
+		return topic
+	}
+	return message.Topic
+}
+
+// nextRetryMessage สร้าง message ที่จะส่งไปยัง retry topic ถัดไปตาม delays
+// หรือไปยัง dead letter topic เมื่อ message ผ่าน retry topic ครบทุกลำดับแล้ว
+// header ของตำแหน่งต้นทางถูกกำหนดเพียงครั้งแรก จึงชี้ไปยัง offset ของ message ใน topic ต้นทางเสมอ
+func nextRetryMessage(message Message, cause error, delays []time.Duration, now time.Time) Message {
+	headers := make(map[string]string, len(message.Headers)+len(retryHeaders))
+	for key, value := range message.Headers {
+		headers[key] = value
+	}
+	if headers[HEADER_ORIGINAL_TOPIC] == "" {
+		headers[HEADER_ORIGINAL_TOPIC] = message.Topic
+		headers[HEADER_ORIGINAL_PARTITION] = strconv.FormatInt(int64(message.Partition), 10)
+		headers[HEADER_ORIGINAL_OFFSET] = strconv.FormatInt(message.Offset, 10)
+	}
+	headers[HEADER_ERROR] = cause.Error()
+
+	attempt, _ := strconv.Atoi(headers[HEADER_RETRY_ATTEMPT])
+	attempt++
+	headers[HEADER_RETRY_ATTEMPT] = strconv.Itoa(attempt)
+
+	var topic string
+	if attempt <= len(delays) {
+		topic = RetryTopic(headers[HEADER_ORIGINAL_TOPIC], attempt)
+		headers[HEADER_RETRY_NOT_BEFORE] = now.Add(delays[attempt-1]).UTC().Format(time.RFC3339Nano)
+	} else {
+		topic = DeadLetterTopic(headers[HEADER_ORIGINAL_TOPIC])
+		headers[HEADER_FAILED_AT] = now.UTC().Format(time.RFC3339Nano)
+		delete(headers, HEADER_RETRY_NOT_BEFORE)
+	}
+
+	return Message{
+		Topic:   topic,
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+	}
+}
+
+// redriveMessage คืน message ใน dead letter topic ในรูปแบบเดิมก่อนถูกส่งไปยัง retry topic
+func redriveMessage(message Message) Message {
+	headers := make(map[string]string, len(message.Headers))
+	for key, value := range message.Headers {
+		headers[key] = value
+	}
+	for _, key := range retryHeaders {
+		delete(headers, key)
+	}
+	return Message{
+		Topic:   OriginalTopic(message),
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+	}
+}
+
+// parkAction คือสิ่งที่ consumer ทำกับ message ของ aggregate ที่อาจมี message ถูกพักอยู่
+type parkAction int
+
+const (
+	// parkActionProcess ประมวลผล message ตามปกติ
+	parkActionProcess parkAction = iota
+	// parkActionUnpark ประมวลผล message ที่ถูกพักซึ่ง version ต่ำที่สุด และนำออกเมื่อสำเร็จ
+	parkActionUnpark
+	// parkActionPark พัก message และส่งตาม message ที่ถูกพักไปใน retry topic
+	parkActionPark
+)
+
+// decideParkAction ตัดสินว่าจะทำอย่างไรกับ message eventID เมื่อ aggregate มี message parkedEventID ถูกพักอยู่
+// message ถูกพักเฉพาะเมื่อ onExhausted เป็น ErrorActionRetryTopic เพราะ retry topic ถูกอ่านเฉพาะใน action นี้
+// หากเปลี่ยน action ระหว่างที่มี message ถูกพัก message ถัดไปจึงถูกประมวลผลตามปกติแทนที่จะถูกพักโดยไม่มีวันถูกอ่าน
+func decideParkAction(onExhausted ErrorAction, parkedEventID int64, parked bool, eventID int64) parkAction {
+	switch {
+	case !parked:
+		return parkActionProcess
+	case parkedEventID == eventID:
+		return parkActionUnpark
+	case onExhausted == ErrorActionRetryTopic:
+		return parkActionPark
+	default:
+		return parkActionProcess
+	}
+}
+
+// waitUntilRetryDelay รอจนถึงเวลาใน HEADER_RETRY_NOT_BEFORE ของ message ใน retry topic
+// message ใน retry topic เดียวกันมี delay เท่ากัน การรอ message แรกจึงไม่ทำให้ message ถัดไปช้ากว่ากำหนด
+func waitUntilRetryDelay(ctx context.Context, message Message) error {
+	notBefore, err := time.Parse(time.RFC3339Nano, message.Headers[HEADER_RETRY_NOT_BEFORE])
+	if err != nil {
+		return nil
+	}
+	delay := time.Until(notBefore)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,221 @@
+package messaging
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNextRetryMessage(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	delays := []time.Duration{10 * time.Second, time.Minute}
+	source := Message{
+		Topic:     "ORDER_EVENT",
+		Partition: 2,
+		Offset:    42,
+		Key:       "order-1",
+		Value:     []byte(`{"order_id":"order-1"}`),
+		Headers:   map[string]string{"event-type": "OrderPlaced"},
+	}
+
+	tests := []struct {
+		name          string
+		tiers         int
+		wantTopic     string
+		wantAttempt   string
+		wantNotBefore string
+		wantFailedAt  string
+	}{
+		{
+			name:          "first failure goes to first retry topic",
+			tiers:         1,
+			wantTopic:     "ORDER_EVENT.retry.1",
+			wantAttempt:   "1",
+			wantNotBefore: now.Add(10 * time.Second).Format(time.RFC3339Nano),
+		},
+		{
+			name:          "second failure goes to second retry topic",
+			tiers:         2,
+			wantTopic:     "ORDER_EVENT.retry.2",
+			wantAttempt:   "2",
+			wantNotBefore: now.Add(time.Minute).Format(time.RFC3339Nano),
+		},
+		{
+			name:         "failure after all retry topics goes to dead letter topic",
+			tiers:        3,
+			wantTopic:    "ORDER_EVENT.dlq",
+			wantAttempt:  "3",
+			wantFailedAt: now.Format(time.RFC3339Nano),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := source
+			for tier := 1; tier <= tt.tiers; tier++ {
+				next := nextRetryMessage(message, errors.New("handler failed"), delays, now)
+				// message ใน retry topic ถูกอ่านด้วย partition และ offset ของ retry topic
+				next.Partition = int32(tier + 10)
+				next.Offset = int64(tier + 100)
+				message = next
+			}
+
+			if message.Topic != tt.wantTopic {
+				t.Errorf("topic = %s, want %s", message.Topic, tt.wantTopic)
+			}
+			if message.Key != source.Key || string(message.Value) != string(source.Value) {
+				t.Errorf("key and value = %s %s, want %s %s", message.Key, message.Value, source.Key, source.Value)
+			}
+			want := map[string]string{
+				"event-type":              "OrderPlaced",
+				HEADER_RETRY_ATTEMPT:      tt.wantAttempt,
+				HEADER_ORIGINAL_TOPIC:     "ORDER_EVENT",
+				HEADER_ORIGINAL_PARTITION: "2",
+				HEADER_ORIGINAL_OFFSET:    "42",
+				HEADER_ERROR:              "handler failed",
+			}
+			if tt.wantNotBefore != "" {
+				want[HEADER_RETRY_NOT_BEFORE] = tt.wantNotBefore
+			}
+			if tt.wantFailedAt != "" {
+				want[HEADER_FAILED_AT] = tt.wantFailedAt
+			}
+			if !reflect.DeepEqual(message.Headers, want) {
+				t.Errorf("headers = %v, want %v", message.Headers, want)
+			}
+		})
+	}
+}
+
+func TestRedriveMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message Message
+		want    Message
+	}{
+		{
+			name: "dead letter message returns to original topic without retry headers",
+			message: Message{
+				Topic:     "ORDER_EVENT.dlq",
+				Partition: 1,
+				Offset:    7,
+				Key:       "order-1",
+				Value:     []byte(`{"order_id":"order-1"}`),
+				Headers: map[string]string{
+					"event-type":              "OrderPlaced",
+					"message-id":              "message-1",
+					HEADER_RETRY_ATTEMPT:      "4",
+					HEADER_ORIGINAL_TOPIC:     "ORDER_EVENT",
+					HEADER_ORIGINAL_PARTITION: "2",
+					HEADER_ORIGINAL_OFFSET:    "42",
+					HEADER_ERROR:              "handler failed",
+					HEADER_FAILED_AT:          "2024-01-02T03:04:05Z",
+				},
+			},
+			want: Message{
+				Topic: "ORDER_EVENT",
+				Key:   "order-1",
+				Value: []byte(`{"order_id":"order-1"}`),
+				Headers: map[string]string{
+					"event-type": "OrderPlaced",
+					"message-id": "message-1",
+				},
+			},
+		},
+		{
+			name: "message without original topic stays on its topic",
+			message: Message{
+				Topic:   "ORDER_EVENT",
+				Key:     "order-2",
+				Value:   []byte(`{}`),
+				Headers: map[string]string{HEADER_RETRY_NOT_BEFORE: "2024-01-02T03:04:05Z"},
+			},
+			want: Message{
+				Topic:   "ORDER_EVENT",
+				Key:     "order-2",
+				Value:   []byte(`{}`),
+				Headers: map[string]string{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := len(tt.message.Headers)
+			got := redriveMessage(tt.message)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redriveMessage() = %+v, want %+v", got, tt.want)
+			}
+			if len(tt.message.Headers) != headers {
+				t.Errorf("redriveMessage() modified the headers of the dead letter message")
+			}
+		})
+	}
+}
+
+func TestDecideParkAction(t *testing.T) {
+	tests := []struct {
+		name          string
+		onExhausted   ErrorAction
+		parkedEventID int64
+		parked        bool
+		eventID       int64
+		want          parkAction
+	}{
+		{
+			name:        "aggregate without parked message is processed",
+			onExhausted: ErrorActionRetryTopic,
+			eventID:     7,
+			want:        parkActionProcess,
+		},
+		{
+			name:          "parked message is processed and unparked",
+			onExhausted:   ErrorActionRetryTopic,
+			parkedEventID: 7,
+			parked:        true,
+			eventID:       7,
+			want:          parkActionUnpark,
+		},
+		{
+			name:          "later message follows parked message to retry topic",
+			onExhausted:   ErrorActionRetryTopic,
+			parkedEventID: 7,
+			parked:        true,
+			eventID:       8,
+			want:          parkActionPark,
+		},
+		{
+			name:          "later message is processed after switching to skip",
+			onExhausted:   ErrorActionSkip,
+			parkedEventID: 7,
+			parked:        true,
+			eventID:       8,
+			want:          parkActionProcess,
+		},
+		{
+			name:          "later message is processed after switching to stop",
+			onExhausted:   ErrorActionStop,
+			parkedEventID: 7,
+			parked:        true,
+			eventID:       8,
+			want:          parkActionProcess,
+		},
+		{
+			name:          "redriven parked message is unparked after switching to skip",
+			onExhausted:   ErrorActionSkip,
+			parkedEventID: 7,
+			parked:        true,
+			eventID:       7,
+			want:          parkActionUnpark,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decideParkAction(tt.onExhausted, tt.parkedEventID, tt.parked, tt.eventID); got != tt.want {
+				t.Errorf("decideParkAction() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
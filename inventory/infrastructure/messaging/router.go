@@ -0,0 +1,72 @@
+package messaging
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
+)
+
+// AnyEventType ใช้ลงทะเบียน handler ที่รับทุก event type ของ topic
+const AnyEventType = "*"
+
+// MessageRouter เลือก MessageHandler ของ message ตาม topic และ event type
+// event type อ่านจาก header HEADER_EVENT_TYPE เนื่องจาก key ของ message คือ aggregate ID
+// handler ที่ลงทะเบียนกับ event type ตรงกันจะถูกเลือกก่อน handler ที่ลงทะเบียนด้วย AnyEventType
+// HasRoutes คืน true เมื่อมี handler ลงทะเบียนกับ topic อย่างน้อยหนึ่ง event type
+type MessageRouter interface {
+	Register(topic string, eventType string, handler MessageHandler)
+	Route(message Message) (MessageHandler, bool)
+	HasRoutes(topic string) bool
+}
+
+type routeKey struct {
+	topic     string
+	eventType string
+}
+
+type messageRouter struct {
+	mu       sync.RWMutex
+	handlers map[routeKey]MessageHandler
+}
+
+func NewMessageRouter() MessageRouter {
+	return &messageRouter{
+		handlers: make(map[routeKey]MessageHandler),
+	}
+}
+
+// Register implements MessageRouter.
+func (r *messageRouter) Register(topic string, eventType string, handler MessageHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := routeKey{topic: topic, eventType: eventType}
+	if _, ok := r.handlers[key]; ok {
+		panic(fmt.Sprintf("message handler for %s %s is already registered", topic, eventType))
+	}
+	r.handlers[key] = handler
+}
+
+// Route implements MessageRouter.
+func (r *messageRouter) Route(message Message) (MessageHandler, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	eventType := message.Headers[contracts.HEADER_EVENT_TYPE]
+	if handler, ok := r.handlers[routeKey{topic: message.Topic, eventType: eventType}]; ok {
+		return handler, true
+	}
+	handler, ok := r.handlers[routeKey{topic: message.Topic, eventType: AnyEventType}]
+	return handler, ok
+}
+
+// HasRoutes implements MessageRouter.
+func (r *messageRouter) HasRoutes(topic string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for key := range r.handlers {
+		if key.topic == topic {
+			return true
+		}
+	}
+	return false
+}
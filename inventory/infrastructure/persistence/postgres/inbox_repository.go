@@ -0,0 +1,141 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/infrastructure/messaging"
+	"github.com/jmoiron/sqlx"
+)
+
+type inboxRepository struct {
+	db *sqlx.DB
+}
+
+// NewInboxRepository ฟังก์ชันสำหรับสร้าง messaging.InboxRepository ใหม่
+func NewInboxRepository(db *sqlx.DB) messaging.InboxRepository {
+	return &inboxRepository{
+		db: db,
+	}
+}
+
+// Begin implements messaging.InboxRepository.
+func (r *inboxRepository) Begin(ctx context.Context) (*sqlx.Tx, error) {
+	return r.db.BeginTxx(ctx, nil)
+}
+
+// SaveTx implements messaging.InboxRepository.
+func (r *inboxRepository) SaveTx(ctx context.Context, tx *sqlx.Tx, message messaging.InboxMessage) (bool, error) {
+	query := `
+INSERT INTO inbox (aggregate_id, event_id, message_id, topic, event_type, event_version, skipped)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (aggregate_id, event_id)
+    DO NOTHING
+	`
+	result, err := tx.ExecContext(ctx, query, message.AggregateID, message.EventID, message.MessageID, message.Topic, message.EventType, message.Version, message.Skipped)
+	if err != nil {
+		return false, fmt.Errorf("failed to save inbox message %s/%d: %w", message.AggregateID, message.EventID, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows > 0, nil
+}
+
+// GetAggregateVersionForUpdateTx implements messaging.InboxRepository.
+// สร้างแถวของ aggregate ก่อนล็อก เพื่อให้ message แรกของ aggregate เดียวกันถูกประมวลผลทีละ message
+func (r *inboxRepository) GetAggregateVersionForUpdateTx(ctx context.Context, tx *sqlx.Tx, aggregateID string) (int, error) {
+	insertQuery := `
+INSERT INTO inbox_aggregate (aggregate_id, version)
+    VALUES ($1, 0)
+ON CONFLICT (aggregate_id)
+    DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, insertQuery, aggregateID); err != nil {
+		return 0, err
+	}
+
+	query := `
+SELECT
+    version
+FROM
+    inbox_aggregate
+WHERE
+    aggregate_id = $1
+FOR UPDATE
+	`
+	var version int
+	if err := tx.GetContext(ctx, &version, query, aggregateID); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// SaveAggregateVersionTx implements messaging.InboxRepository.
+func (r *inboxRepository) SaveAggregateVersionTx(ctx context.Context, tx *sqlx.Tx, aggregateID string, version int) error {
+	query := `
+UPDATE
+    inbox_aggregate
+SET
+    version = $2,
+    updated_at = now()
+WHERE
+    aggregate_id = $1
+	`
+	_, err := tx.ExecContext(ctx, query, aggregateID, version)
+	return err
+}
+
+// ParkTx implements messaging.InboxRepository.
+func (r *inboxRepository) ParkTx(ctx context.Context, tx *sqlx.Tx, message messaging.InboxMessage) error {
+	query := `
+INSERT INTO inbox_parked (aggregate_id, event_id, message_id, event_version)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT (aggregate_id, event_id)
+    DO NOTHING
+	`
+	if _, err := tx.ExecContext(ctx, query, message.AggregateID, message.EventID, message.MessageID, message.Version); err != nil {
+		return fmt.Errorf("failed to park inbox message %s/%d: %w", message.AggregateID, message.EventID, err)
+	}
+	return nil
+}
+
+// UnparkTx implements messaging.InboxRepository.
+func (r *inboxRepository) UnparkTx(ctx context.Context, tx *sqlx.Tx, aggregateID string, eventID int64) error {
+	query := `
+DELETE FROM inbox_parked
+WHERE aggregate_id = $1
+    AND event_id = $2
+	`
+	if _, err := tx.ExecContext(ctx, query, aggregateID, eventID); err != nil {
+		return fmt.Errorf("failed to unpark inbox message %s/%d: %w", aggregateID, eventID, err)
+	}
+	return nil
+}
+
+// GetFirstParkedEventTx implements messaging.InboxRepository.
+func (r *inboxRepository) GetFirstParkedEventTx(ctx context.Context, tx *sqlx.Tx, aggregateID string) (int64, bool, error) {
+	query := `
+SELECT
+    event_id
+FROM
+    inbox_parked
+WHERE
+    aggregate_id = $1
+ORDER BY
+    event_version,
+    event_id
+LIMIT 1
+	`
+	var eventID int64
+	if err := tx.GetContext(ctx, &eventID, query, aggregateID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to read parked inbox message of aggregate %s: %w", aggregateID, err)
+	}
+	return eventID, true, nil
+}
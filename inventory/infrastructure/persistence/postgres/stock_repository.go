@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/domain/stock"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type stockRepository struct {
+	db *sqlx.DB
+}
+
+type stockRow struct {
+	ItemID   string `db:"item_id"`
+	Name     string `db:"name"`
+	OnHand   int    `db:"on_hand"`
+	Reserved int    `db:"reserved"`
+}
+
+func (r stockRow) toStock() *stock.Stock {
+	return &stock.Stock{
+		ItemID:   r.ItemID,
+		Name:     r.Name,
+		OnHand:   r.OnHand,
+		Reserved: r.Reserved,
+	}
+}
+
+// NewStockRepository ฟังก์ชันสำหรับสร้าง StockRepository ใหม่
+func NewStockRepository(db *sqlx.DB) stock.StockRepository {
+	return &stockRepository{
+		db: db,
+	}
+}
+
+// Begin implements stock.StockRepository.
+func (r *stockRepository) Begin(ctx context.Context) (*sqlx.Tx, error) {
+	return r.db.BeginTxx(ctx, nil)
+}
+
+// GetStocks implements stock.StockRepository.
+func (r *stockRepository) GetStocks(ctx context.Context) ([]stock.Stock, error) {
+	query := `
+SELECT
+    item_id, name, on_hand, reserved
+FROM
+    stocks
+ORDER BY
+    item_id
+	`
+	var rows []stockRow
+	if err := r.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+
+	stocks := make([]stock.Stock, 0, len(rows))
+	for _, row := range rows {
+		stocks = append(stocks, *row.toStock())
+	}
+	return stocks, nil
+}
+
+// GetStockForUpdateTx implements stock.StockRepository.
+func (r *stockRepository) GetStockForUpdateTx(ctx context.Context, tx *sqlx.Tx, itemID string) (*stock.Stock, error) {
+	query := `
+SELECT
+    item_id, name, on_hand, reserved
+FROM
+    stocks
+WHERE
+    item_id = $1
+FOR UPDATE
+	`
+	var row stockRow
+	if err := tx.GetContext(ctx, &row, query, itemID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, stock.ErrStockNotFound
+		}
+		return nil, err
+	}
+	return row.toStock(), nil
+}
+
+// GetStocksForUpdateTx implements stock.StockRepository.
+// ล็อกแถวตามลำดับ item_id เพื่อป้องกัน deadlock ระหว่าง order ที่จองสินค้าชุดเดียวกัน
+func (r *stockRepository) GetStocksForUpdateTx(ctx context.Context, tx *sqlx.Tx, itemIDs []string) (map[string]*stock.Stock, error) {
+	query := `
+SELECT
+    item_id, name, on_hand, reserved
+FROM
+    stocks
+WHERE
+    item_id = ANY($1)
+ORDER BY
+    item_id
+FOR UPDATE
+	`
+	var rows []stockRow
+	if err := tx.SelectContext(ctx, &rows, query, pq.Array(itemIDs)); err != nil {
+		return nil, err
+	}
+
+	stocks := make(map[string]*stock.Stock, len(rows))
+	for _, row := range rows {
+		stocks[row.ItemID] = row.toStock()
+	}
+	return stocks, nil
+}
+
+// SaveStockTx implements stock.StockRepository.
+func (r *stockRepository) SaveStockTx(ctx context.Context, tx *sqlx.Tx, s *stock.Stock) error {
+	query := `
+INSERT INTO stocks (item_id, name, on_hand, reserved)
+    VALUES ($1, $2, $3, $4)
+ON CONFLICT (item_id)
+    DO UPDATE SET
+        name = $2, on_hand = $3, reserved = $4, updated_at = now()
+	`
+	if _, err := tx.ExecContext(ctx, query, s.ItemID, s.Name, s.OnHand, s.Reserved); err != nil {
+		return fmt.Errorf("failed to save stock %s: %w", s.ItemID, err)
+	}
+	return nil
+}
+
+// GetReservationsForUpdateTx implements stock.StockRepository.
+// คืนจำนวนที่จองของ order แยกตามสินค้า
+func (r *stockRepository) GetReservationsForUpdateTx(ctx context.Context, tx *sqlx.Tx, orderID string) (map[string]int, error) {
+	query := `
+SELECT
+    item_id, quantity
+FROM
+    reservations
+WHERE
+    order_id = $1
+FOR UPDATE
+	`
+	var rows []struct {
+		ItemID   string `db:"item_id"`
+		Quantity int    `db:"quantity"`
+	}
+	if err := tx.SelectContext(ctx, &rows, query, orderID); err != nil {
+		return nil, err
+	}
+
+	reservations := make(map[string]int, len(rows))
+	for _, row := range rows {
+		reservations[row.ItemID] = row.Quantity
+	}
+	return reservations, nil
+}
+
+// SaveReservationTx implements stock.StockRepository.
+// reservation ที่ Quantity เป็น 0 จะถูกลบ
+func (r *stockRepository) SaveReservationTx(ctx context.Context, tx *sqlx.Tx, reservation stock.Reservation) error {
+	if reservation.Quantity == 0 {
+		query := `
+DELETE FROM reservations
+WHERE
+    order_id = $1
+AND
+    item_id = $2
+		`
+		_, err := tx.ExecContext(ctx, query, reservation.OrderID, reservation.ItemID)
+		return err
+	}
+
+	query := `
+INSERT INTO reservations (order_id, item_id, quantity)
+    VALUES ($1, $2, $3)
+ON CONFLICT (order_id, item_id)
+    DO UPDATE SET
+        quantity = $3, updated_at = now()
+	`
+	_, err := tx.ExecContext(ctx, query, reservation.OrderID, reservation.ItemID, reservation.Quantity)
+	return err
+}
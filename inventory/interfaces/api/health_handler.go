@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/application"
+	"github.com/labstack/echo/v4"
+)
+
+type HealthHandler interface {
+	HealthzHandler(c echo.Context) error
+	ReadyzHandler(c echo.Context) error
+}
+
+type healthHandler struct {
+	healthUsecase application.HealthUsecase
+}
+
+// HealthzHandler implements HealthHandler.
+func (h *healthHandler) HealthzHandler(c echo.Context) error {
+	return healthResponse(c, h.healthUsecase.Liveness())
+}
+
+// ReadyzHandler implements HealthHandler.
+func (h *healthHandler) ReadyzHandler(c echo.Context) error {
+	return healthResponse(c, h.healthUsecase.Readiness())
+}
+
+// healthResponse ตอบ 200 เมื่อสถานะเป็น UP และ 503 เมื่อเป็น DOWN
+func healthResponse(c echo.Context, status application.HealthStatus) error {
+	if !status.Up() {
+		return c.JSON(http.StatusServiceUnavailable, status)
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+func NewHealthHandler(healthUsecase application.HealthUsecase) HealthHandler {
+	return &healthHandler{
+		healthUsecase: healthUsecase,
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/application"
+	"github.com/labstack/echo/v4"
+)
+
+type StockHandler interface {
+	GetStocksHandler(c echo.Context) error
+	SetStockHandler(c echo.Context) error
+}
+
+type stockHandler struct {
+	stockUsecase application.StockUsecase
+}
+
+type setStockRequest struct {
+	Name   string `json:"name"`
+	OnHand int    `json:"on_hand"`
+}
+
+// GetStocksHandler implements StockHandler.
+func (h *stockHandler) GetStocksHandler(c echo.Context) error {
+	stocks, err := h.stockUsecase.GetStocks(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	resp := map[string]interface{}{
+		"stocks": stocks,
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// SetStockHandler implements StockHandler.
+func (h *stockHandler) SetStockHandler(c echo.Context) error {
+	setStockRequest := setStockRequest{}
+
+	if err := c.Bind(&setStockRequest); err != nil {
+		return err
+	}
+
+	s, err := h.stockUsecase.SetStock(c.Request().Context(), c.Param("item_id"), setStockRequest.Name, setStockRequest.OnHand)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, s)
+}
+
+func NewStockHandler(stockUsecase application.StockUsecase) StockHandler {
+	return &stockHandler{
+		stockUsecase: stockUsecase,
+	}
+}
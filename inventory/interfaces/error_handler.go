@@ -0,0 +1,93 @@
+package interfaces
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/domain/stock"
+	"github.com/labstack/echo/v4"
+)
+
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Problem คือ response body ตาม RFC 7807 พร้อม code สำหรับให้ frontend ใช้แสดงข้อความ
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings จับคู่ domain error กับ HTTP status และ error code
+var errorMappings = []errorMapping{
+	{stock.ErrStockNotFound, http.StatusNotFound, "STOCK_NOT_FOUND"},
+	{stock.ErrOnHandLessThanZero, http.StatusUnprocessableEntity, "ON_HAND_LESS_THAN_ZERO"},
+	{stock.ErrOnHandLessThanReserved, http.StatusUnprocessableEntity, "ON_HAND_LESS_THAN_RESERVED"},
+}
+
+// HTTPErrorHandler แปลง error ที่คืนจาก handler เป็น application/problem+json
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := newProblem(err)
+	problem.Instance = c.Request().URL.Path
+	if problem.Status == http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		err = c.JSON(problem.Status, problem)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+func newProblem(err error) Problem {
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.err) {
+			return Problem{
+				Type:   "about:blank",
+				Title:  http.StatusText(mapping.status),
+				Status: mapping.status,
+				Detail: err.Error(),
+				Code:   mapping.code,
+			}
+		}
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		problem := Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(httpErr.Code),
+			Status: httpErr.Code,
+			Code:   strings.ToUpper(strings.ReplaceAll(http.StatusText(httpErr.Code), " ", "_")),
+		}
+		if message, ok := httpErr.Message.(string); ok {
+			problem.Detail = message
+		}
+		return problem
+	}
+
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+		Code:   "INTERNAL_SERVER_ERROR",
+	}
+}
@@ -0,0 +1,30 @@
+package interfaces
+
+import (
+	"github.com/Bass-Peerapon/eventsource-demo/inventory/interfaces/api"
+	"github.com/labstack/echo/v4"
+)
+
+type Route struct {
+	e *echo.Echo
+}
+
+func NewRoute(e *echo.Echo) *Route {
+	return &Route{
+		e: e,
+	}
+}
+
+func (r *Route) RegisterHTTPErrorHandler() {
+	r.e.HTTPErrorHandler = HTTPErrorHandler
+}
+
+func (r *Route) RegisterStockHandler(h api.StockHandler) {
+	r.e.GET("/stocks", h.GetStocksHandler)
+	r.e.PUT("/stocks/:item_id", h.SetStockHandler)
+}
+
+func (r *Route) RegisterHealthHandler(h api.HealthHandler) {
+	r.e.GET("/healthz", h.HealthzHandler)
+	r.e.GET("/readyz", h.ReadyzHandler)
+}
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS stocks (
+  item_id     TEXT       PRIMARY KEY,
+  name        TEXT       NOT NULL DEFAULT '',
+  on_hand     INTEGER    NOT NULL CHECK (on_hand >= 0),
+  reserved    INTEGER    NOT NULL DEFAULT 0 CHECK (reserved >= 0 AND reserved <= on_hand),
+  created_at  TIMESTAMP  NOT NULL DEFAULT now(),
+  updated_at  TIMESTAMP  NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS reservations (
+  order_id    TEXT       NOT NULL,
+  item_id     TEXT       NOT NULL REFERENCES stocks (item_id),
+  quantity    INTEGER    NOT NULL CHECK (quantity > 0),
+  created_at  TIMESTAMP  NOT NULL DEFAULT now(),
+  updated_at  TIMESTAMP  NOT NULL DEFAULT now(),
+  PRIMARY KEY (order_id, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS IDX_RESERVATIONS_ITEM_ID ON reservations (item_id);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS outbox (
+  position      BIGSERIAL    PRIMARY KEY,
+  message_id    UUID         NOT NULL UNIQUE,
+  topic         TEXT         NOT NULL,
+  message_key   TEXT         NOT NULL,
+  payload       JSONB        NOT NULL,
+  headers       JSONB        NOT NULL DEFAULT '{}',
+  created_at    TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  published_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS IDX_OUTBOX_UNPUBLISHED ON outbox (position) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS IDX_OUTBOX_PUBLISHED_AT ON outbox (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,2 @@
+DROP TABLE inbox_aggregate;
+DROP TABLE inbox;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS inbox (
+  aggregate_id   TEXT       NOT NULL,
+  event_id       BIGINT     NOT NULL,
+  message_id     TEXT       NOT NULL,
+  topic          TEXT       NOT NULL,
+  event_type     TEXT       NOT NULL,
+  event_version  INTEGER    NOT NULL,
+  skipped        BOOLEAN    NOT NULL DEFAULT false,
+  processed_at   TIMESTAMP  NOT NULL DEFAULT now(),
+  PRIMARY KEY (aggregate_id, event_id)
+);
+
+CREATE TABLE IF NOT EXISTS inbox_aggregate (
+  aggregate_id  TEXT       PRIMARY KEY,
+  version       INTEGER    NOT NULL,
+  updated_at    TIMESTAMP  NOT NULL DEFAULT now()
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS inbox_parked (
+  aggregate_id   TEXT         NOT NULL,
+  event_id       BIGINT       NOT NULL,
+  message_id     TEXT         NOT NULL,
+  event_version  INTEGER      NOT NULL,
+  parked_at      TIMESTAMPTZ  NOT NULL DEFAULT now(),
+  PRIMARY KEY (aggregate_id, event_id)
+);
@@ -1,114 +1,53 @@
 package application
 
 import (
+	"context"
 	"errors"
+	"fmt"
 
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
-	"github.com/Bass-Peerapon/eventsource-demo/ordering/infrastructure/persistence/postgres"
 	"github.com/gofrs/uuid"
 )
 
+var ErrExpectedVersionMismatch = errors.New("expected version does not match")
+
+// CommandOrderUsecase ทำ command กับ order และคืน version ของ aggregate ใน event store หลัง commit
+// version นี้ไม่ขึ้นกับ read model จึงใช้เป็น expected version ของ command ถัดไปได้ทันที
 type CommandOrderUsecase interface {
-	CreateOrder(name string, orderItems []order.OrderItem) error
-	UpdatedOrder(id uuid.UUID, name string, orderItems []order.OrderItem) error
-	UpdateOrderItemAmount(id uuid.UUID, orderItemID uuid.UUID, amount int) error
+	CreateOrder(ctx context.Context, name string, orderItems []order.OrderItem) (int, error)
+	UpdatedOrder(ctx context.Context, id uuid.UUID, expectedVersion *int, name string, orderItems []order.OrderItem) (int, error)
+	UpdateOrderItemAmount(ctx context.Context, id uuid.UUID, expectedVersion *int, orderItemID uuid.UUID, amount int) (int, error)
+	SubmitOrder(ctx context.Context, id uuid.UUID) (int, error)
+	CancelOrder(ctx context.Context, id uuid.UUID, reason string) (int, error)
 }
 
 type commandOrderUsecase struct {
-	eventRepo       core.EventRepository
-	aggregateRepo   core.AggregateRepository
-	orderProjection OrderProjection
+	aggregateLoader core.AggregateLoader
+	newUnitOfWork   UnitOfWorkFactory
+	retryPolicy     RetryPolicy
 }
 
 // CreateOrder implements OrderUsecase.
-func (o *commandOrderUsecase) CreateOrder(name string, orderItems []order.OrderItem) error {
+func (o *commandOrderUsecase) CreateOrder(ctx context.Context, name string, orderItems []order.OrderItem) (int, error) {
 	order := order.CreateOrderWithItems(name, orderItems)
-	if err := o.aggregateRepo.SaveAggregate(order); err != nil {
-		return err
-	}
-	if err := o.eventRepo.SaveEvents(order.Events); err != nil {
-		return err
-	}
-	if err := o.orderProjection.HandleEvent(order); err != nil {
-		return err
+	uow := o.newUnitOfWork()
+	uow.Track(order, 0, order.Events)
+	if err := uow.Commit(ctx); err != nil {
+		return 0, err
 	}
-	return nil
+	return order.Version, nil
 }
 
 // UpdateOrderItemAmount implements OrderUsecase.
-func (o *commandOrderUsecase) UpdateOrderItemAmount(id uuid.UUID, orderItemID uuid.UUID, amount int) error {
-	orderAggregate := order.OrderAggregate{}
-	snapshot, err := o.aggregateRepo.LoadSnapshot(id, nil)
-	if err != nil {
-		return err
-	}
-	if snapshot != nil {
-		snapshot.UnSerialize(&orderAggregate)
-	}
-	loadedEvents, err := o.eventRepo.LoadEvents(id, &orderAggregate.Version, nil)
-	if err != nil {
-		return err
-	}
-
-	for _, event := range loadedEvents {
-		orderAggregate.Apply(event)
-	}
-
-	if err := orderAggregate.UpdateOrderItemAmount(orderItemID, amount); err != nil {
-		return err
-	}
-
-	if err := o.aggregateRepo.SaveAggregate(&orderAggregate); err != nil {
-		if errors.Is(err, postgres.ErrAggregateOutdated) {
-			return o.UpdateOrderItemAmount(id, orderItemID, amount)
-		}
-		return err
-	}
-	if err := o.eventRepo.SaveEvents(orderAggregate.Events); err != nil {
-		return err
-	}
-
-	for _, event := range orderAggregate.Events {
-		if event.Version%10 == 0 {
-			snapshot := core.AggregateSnapshot{
-				AggregateID: id,
-				Version:     event.Version,
-				EventData:   event.EventData,
-			}
-			if err := o.aggregateRepo.SaveSnapshot(&snapshot); err != nil {
-				return err
-			}
-		}
-	}
-
-	if err := o.orderProjection.HandleEvent(&orderAggregate); err != nil {
-		return err
-	}
-
-	return nil
+func (o *commandOrderUsecase) UpdateOrderItemAmount(ctx context.Context, id uuid.UUID, expectedVersion *int, orderItemID uuid.UUID, amount int) (int, error) {
+	return o.executeOnOrder(ctx, id, expectedVersion, func(orderAggregate *order.OrderAggregate) error {
+		return orderAggregate.UpdateOrderItemAmount(orderItemID, amount)
+	})
 }
 
 // UpdatedOrder implements OrderUsecase.
-func (o *commandOrderUsecase) UpdatedOrder(id uuid.UUID, name string, orderItems []order.OrderItem) error {
-	orderAggregate := order.OrderAggregate{}
-	snapshot, err := o.aggregateRepo.LoadSnapshot(id, nil)
-	if err != nil {
-		return err
-	}
-	if snapshot != nil {
-		snapshot.UnSerialize(&orderAggregate)
-	}
-
-	loadedEvents, err := o.eventRepo.LoadEvents(id, &orderAggregate.Version, nil)
-	if err != nil {
-		return err
-	}
-
-	for _, event := range loadedEvents {
-		orderAggregate.Apply(event)
-	}
-
+func (o *commandOrderUsecase) UpdatedOrder(ctx context.Context, id uuid.UUID, expectedVersion *int, name string, orderItems []order.OrderItem) (int, error) {
 	items := make([]order.OrderItem, 0, len(orderItems))
 	for _, v := range orderItems {
 		items = append(items, order.OrderItem{
@@ -118,43 +57,78 @@ func (o *commandOrderUsecase) UpdatedOrder(id uuid.UUID, name string, orderItems
 		})
 	}
 
-	if err := orderAggregate.UpdatedOrderWithItems(name, items); err != nil {
-		return err
-	}
-	if err := o.aggregateRepo.SaveAggregate(&orderAggregate); err != nil {
-		if errors.Is(err, postgres.ErrAggregateOutdated) {
-			return o.UpdatedOrder(id, name, orderItems)
+	return o.executeOnOrder(ctx, id, expectedVersion, func(orderAggregate *order.OrderAggregate) error {
+		return orderAggregate.UpdatedOrderWithItems(name, items)
+	})
+}
+
+// SubmitOrder implements OrderUsecase.
+func (o *commandOrderUsecase) SubmitOrder(ctx context.Context, id uuid.UUID) (int, error) {
+	return o.executeOnOrder(ctx, id, nil, func(orderAggregate *order.OrderAggregate) error {
+		return orderAggregate.Submit()
+	})
+}
+
+// CancelOrder implements OrderUsecase.
+func (o *commandOrderUsecase) CancelOrder(ctx context.Context, id uuid.UUID, reason string) (int, error) {
+	return o.executeOnOrder(ctx, id, nil, func(orderAggregate *order.OrderAggregate) error {
+		return orderAggregate.Cancel(reason)
+	})
+}
+
+// executeOnOrder โหลด order เรียก command แล้ว commit ผ่าน unit of work
+// หาก aggregate ถูกแก้ไขไปก่อนจะโหลดใหม่และทำซ้ำตาม retry policy
+// ถ้าระบุ expectedVersion และไม่ตรงกับ version ของ order จะคืน ErrExpectedVersionMismatch
+// คืน version ของ order หลัง commit สำเร็จ
+func (o *commandOrderUsecase) executeOnOrder(ctx context.Context, id uuid.UUID, expectedVersion *int, command func(orderAggregate *order.OrderAggregate) error) (int, error) {
+	var version int
+	err := o.retryPolicy.Do(ctx, func() error {
+		orderAggregate, err := o.loadOrder(ctx, id)
+		if err != nil {
+			return err
 		}
-		return err
-	}
+		if expectedVersion != nil && *expectedVersion != orderAggregate.Version {
+			return fmt.Errorf("%w: expected %d, actual %d", ErrExpectedVersionMismatch, *expectedVersion, orderAggregate.Version)
+		}
+		loadedVersion := orderAggregate.Version
 
-	if err := o.eventRepo.SaveEvents(orderAggregate.Events); err != nil {
-		return err
-	}
+		if err := command(orderAggregate); err != nil {
+			return err
+		}
 
-	for _, event := range orderAggregate.Events {
-		if event.Version%10 == 0 {
-			snapshot := core.AggregateSnapshot{
-				AggregateID: id,
-				Version:     event.Version,
-				EventData:   event.EventData,
-			}
-			if err := o.aggregateRepo.SaveSnapshot(&snapshot); err != nil {
-				return err
-			}
+		uow := o.newUnitOfWork()
+		uow.Track(orderAggregate, loadedVersion, orderAggregate.Events)
+		if err := uow.Commit(ctx); err != nil {
+			return err
 		}
+		version = orderAggregate.Version
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
+	return version, nil
+}
 
-	if err := o.orderProjection.HandleEvent(&orderAggregate); err != nil {
-		return err
+// loadOrder โหลด OrderAggregate และคืน order.ErrOrderNotFound หากไม่พบ event ของ order นั้น
+func (o *commandOrderUsecase) loadOrder(ctx context.Context, id uuid.UUID) (*order.OrderAggregate, error) {
+	if id == uuid.Nil {
+		return nil, order.ErrOrderNotFound
+	}
+	orderAggregate, err := core.LoadAggregate[order.OrderAggregate](ctx, o.aggregateLoader, id, nil)
+	if err != nil {
+		if errors.Is(err, core.ErrAggregateNotFound) {
+			return nil, order.ErrOrderNotFound
+		}
+		return nil, err
 	}
-	return nil
+	return orderAggregate, nil
 }
 
-func NewCommandOrderUsecase(eventStore core.EventRepository, aggregateRepo core.AggregateRepository, orderProjection OrderProjection) CommandOrderUsecase {
+func NewCommandOrderUsecase(aggregateLoader core.AggregateLoader, newUnitOfWork UnitOfWorkFactory, retryPolicy RetryPolicy) CommandOrderUsecase {
 	return &commandOrderUsecase{
-		eventRepo:       eventStore,
-		aggregateRepo:   aggregateRepo,
-		orderProjection: orderProjection,
+		aggregateLoader: aggregateLoader,
+		newUnitOfWork:   newUnitOfWork,
+		retryPolicy:     retryPolicy,
 	}
 }
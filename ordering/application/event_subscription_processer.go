@@ -1,96 +1,263 @@
 package application
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"runtime/debug"
 	"time"
 
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/helper"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/infrastructure/persistence/postgres"
 )
 
+// DefaultSubscriptionBatchSize คือจำนวน event สูงสุดที่อ่านจาก event store ในแต่ละ batch
+const DefaultSubscriptionBatchSize = 100
+
+// DefaultEventRetryPolicy กำหนดจำนวนครั้งที่ทำซ้ำ handler ต่อหนึ่ง event ก่อนย้ายไป es_event_dead_letter
+// ทุก error ถูกทำซ้ำจนครบ MaxAttempts แต่ event ที่ยังล้มเหลวด้วย error ชั่วคราวของ database จะไม่ถูกย้ายไป dead letter
+func DefaultEventRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+		Retryable:      func(err error) bool { return true },
+	}
+}
+
+// EventSubscriptionProcessor ประมวลผล event ของ subscription ทีละ batch
+// ProcessNewEvents ประมวลผลหนึ่ง batch และ CatchUp ประมวลผลต่อเนื่องจนกว่าจะไม่มี event ค้าง
 type EventSubscriptionProcessor interface {
-	ProcessNewEvents(eventHandler core.AsyncEventHandler)
+	ProcessNewEvents(ctx context.Context, eventHandler core.AsyncEventHandler) (int, error)
+	CatchUp(ctx context.Context, eventHandler core.AsyncEventHandler) (int, error)
+	RedriveDeadLetters(ctx context.Context, eventHandler core.AsyncEventHandler) (int, error)
 }
 
 // EventSubscriptionProcessor ใช้สำหรับจัดการ event subscription
 type eventSubscriptionProcessor struct {
 	subscriptionRepository core.EventSubscriptionRepository
 	eventRepository        core.EventRepository
+	batchSize              int
+	retryPolicy            RetryPolicy
 }
 
-func NewEventSubscriptionProcessor(subscriptionRepository core.EventSubscriptionRepository, eventRepository core.EventRepository) EventSubscriptionProcessor {
+func NewEventSubscriptionProcessor(subscriptionRepository core.EventSubscriptionRepository, eventRepository core.EventRepository, batchSize int, retryPolicy RetryPolicy) EventSubscriptionProcessor {
+	if batchSize <= 0 {
+		batchSize = DefaultSubscriptionBatchSize
+	}
 	return &eventSubscriptionProcessor{
 		subscriptionRepository: subscriptionRepository,
 		eventRepository:        eventRepository,
+		batchSize:              batchSize,
+		retryPolicy:            retryPolicy,
 	}
 }
 
-func (p *eventSubscriptionProcessor) ProcessNewEvents(eventHadnler core.AsyncEventHandler) {
-	defer func() {
-		if err := recover(); err != nil {
-			debug.PrintStack()
-			log.Println(err)
-		}
-	}()
-	// Polling every 1 second
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			// Poll and process new events
-
-			if err := p.processNewEvents(eventHadnler); err != nil {
-				helper.Println(fmt.Sprintf("Error processing new events: %v", err))
+// CatchUp implements EventSubscriptionProcessor.
+// แต่ละ batch commit checkpoint ของตัวเอง และจะหยุดระหว่าง batch เมื่อ ctx ถูกยกเลิก
+// งานของ database ใน batch ที่เริ่มแล้วไม่ใช้ ctx เพื่อให้ event ปัจจุบัน commit ได้ครบก่อนหยุดเมื่อ shutdown
+// แต่การรอ backoff ระหว่างทำซ้ำ handler จะหยุดทันทีเมื่อ ctx ถูกยกเลิก
+func (p *eventSubscriptionProcessor) CatchUp(ctx context.Context, eventHandler core.AsyncEventHandler) (int, error) {
+	total := 0
+	for ctx.Err() == nil {
+		processed, err := p.processBatch(context.Background(), ctx, eventHandler)
+		total += processed
+		if err != nil {
+			// หยุดระหว่างรอ backoff เมื่อ shutdown ไม่ถือเป็นความล้มเหลวของ subscription
+			if ctx.Err() != nil {
+				return total, nil
 			}
+			return total, err
+		}
+		if processed < p.batchSize {
+			break
 		}
 	}
+	return total, nil
 }
 
-// ProcessNewEvents ใช้ในการประมวลผลเหตุการณ์ใหม่
-func (p *eventSubscriptionProcessor) processNewEvents(eventHandler core.AsyncEventHandler) error {
+// ProcessNewEvents ใช้ในการประมวลผลเหตุการณ์ใหม่หนึ่ง batch และคืนจำนวนเหตุการณ์ที่ประมวลผลแล้ว
+// checkpoint ถูก commit หลังประมวลผลแต่ละเหตุการณ์ เหตุการณ์ก่อนหน้าจึงไม่ถูกประมวลผลซ้ำเมื่อเหตุการณ์ถัดไปล้มเหลว
+func (p *eventSubscriptionProcessor) ProcessNewEvents(ctx context.Context, eventHandler core.AsyncEventHandler) (int, error) {
+	return p.processBatch(ctx, ctx, eventHandler)
+}
+
+// processBatch ประมวลผลหนึ่ง batch โดยใช้ ctx กับ database และ handler
+// และหยุดรอ backoff ของการทำซ้ำเมื่อ stop ถูกยกเลิก
+func (p *eventSubscriptionProcessor) processBatch(ctx context.Context, stop context.Context, eventHandler core.AsyncEventHandler) (int, error) {
 	// สร้าง subscription หากยังไม่มี
-	err := p.subscriptionRepository.CreateSubscription(eventHandler.GetSubscriptionName())
+	err := p.subscriptionRepository.CreateSubscription(ctx, eventHandler.GetSubscriptionName())
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	checkpoint, events, err := p.readBatch(ctx, eventHandler)
+	if err != nil || checkpoint == nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, event := range events {
+		ok, err := p.processEvent(ctx, stop, eventHandler, *checkpoint, event)
+		if err != nil {
+			return processed, err
+		}
+		if !ok {
+			// subscription ถูกล็อกหรือถูกเลื่อน checkpoint โดย process อื่น
+			break
+		}
+		processed++
+		checkpoint = &core.EventSubscriptionCheckpoint{
+			LasttransactionID: event.TransactionID,
+			LastEventID:       event.ID,
+		}
 	}
+	return processed, nil
+}
 
+// readBatch อ่าน checkpoint และเหตุการณ์ใหม่ที่อยู่หลัง checkpoint ไม่เกิน batchSize รายการ
+// คืน checkpoint เป็น nil หาก subscription ถูกล็อกโดย process อื่นอยู่
+func (p *eventSubscriptionProcessor) readBatch(ctx context.Context, eventHandler core.AsyncEventHandler) (*core.EventSubscriptionCheckpoint, []core.Event, error) {
 	// อ่าน checkpoint และล็อก subscription
-	tx, checkpoint, err := p.subscriptionRepository.ReadCheckpointAndLockSubscription(eventHandler.GetSubscriptionName())
+	tx, checkpoint, err := p.subscriptionRepository.ReadCheckpointAndLockSubscription(ctx, eventHandler.GetSubscriptionName())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
 	defer tx.Rollback()
+
+	if checkpoint == nil {
+		return nil, nil, tx.Commit()
+	}
+
+	helper.Println(fmt.Sprintf("Acquired lock on subscription %s, checkpoint = %+v", eventHandler.GetSubscriptionName(), checkpoint))
+
+	// อ่านเหตุการณ์ใหม่ที่อยู่หลัง checkpoint
+	events, err := p.subscriptionRepository.ReadEventsAfterCheckpoint(ctx, tx, eventHandler.GetAggregateType(), checkpoint.LasttransactionID, checkpoint.LastEventID, p.batchSize)
 	if err != nil {
-		return fmt.Errorf("failed to read checkpoint: %w", err)
+		return nil, nil, fmt.Errorf("failed to read new events: %w", err)
 	}
 
-	if checkpoint != nil {
-		helper.Println(fmt.Sprintf("Acquired lock on subscription %s, checkpoint = %+v", eventHandler.GetSubscriptionName(), checkpoint))
+	helper.Println(fmt.Sprintf("Fetched %d new event(s) for subscription %s", len(events), eventHandler.GetSubscriptionName()))
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return checkpoint, events, nil
+}
 
-		// อ่านเหตุการณ์ใหม่ที่อยู่หลัง checkpoint
-		events, err := p.subscriptionRepository.ReadEventsAfterCheckpoint(tx, eventHandler.GetAggregateType(), checkpoint.LasttransactionID, checkpoint.LastEventID)
-		if err != nil {
-			return fmt.Errorf("failed to read new events: %w", err)
+// processEvent ล็อก subscription ประมวลผลเหตุการณ์หนึ่งรายการ และ commit checkpoint ของเหตุการณ์นั้น
+// คืน false หาก checkpoint ปัจจุบันไม่ตรงกับ expected ซึ่งหมายถึงมี process อื่นทำงานกับ subscription นี้อยู่
+// หาก stop ถูกยกเลิกระหว่างทำซ้ำ checkpoint จะไม่ถูกเลื่อนและเหตุการณ์จะถูกประมวลผลอีกครั้งเมื่อเริ่มใหม่
+func (p *eventSubscriptionProcessor) processEvent(ctx context.Context, stop context.Context, eventHandler core.AsyncEventHandler, expected core.EventSubscriptionCheckpoint, event core.Event) (bool, error) {
+	tx, checkpoint, err := p.subscriptionRepository.ReadCheckpointAndLockSubscription(ctx, eventHandler.GetSubscriptionName())
+	if err != nil {
+		return false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	defer tx.Rollback()
+
+	if checkpoint == nil || *checkpoint != expected {
+		return false, tx.Commit()
+	}
+
+	// ประมวลผลเหตุการณ์ หากล้มเหลวจนครบจำนวนครั้งให้ย้ายไป dead letter แทนการหยุด subscription
+	// ยกเว้น error ชั่วคราวของ database ซึ่ง rollback โดยไม่เลื่อน checkpoint เพื่อให้ทำซ้ำในรอบ poll ถัดไป
+	if attempts, err := p.handleWithRetry(ctx, stop, eventHandler, event); err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if stop.Err() != nil {
+			return false, stop.Err()
+		}
+		if postgres.IsTransient(err) {
+			return false, fmt.Errorf("failed to handle event %d after %d attempt(s): %w", event.ID, attempts, err)
 		}
+		helper.Println(fmt.Sprintf("Moving event %d of subscription %s to dead letter after %d attempt(s): %v", event.ID, eventHandler.GetSubscriptionName(), attempts, err))
+		if err := p.subscriptionRepository.SaveDeadLetter(ctx, tx, eventHandler.GetSubscriptionName(), event, attempts, err.Error()); err != nil {
+			return false, err
+		}
+	}
 
-		helper.Println(fmt.Sprintf("Fetched %d new event(s) for subscription %s", len(events), eventHandler.GetSubscriptionName()))
-		if len(events) > 0 {
-			for _, event := range events {
-				// ประมวลผลแต่ละเหตุการณ์
-				err := eventHandler.HandleEvent(event)
-				if err != nil {
-					return fmt.Errorf("failed to handle event: %w", err)
-				}
-			}
+	// อัปเดต subscription ด้วยเหตุการณ์ล่าสุดที่ประมวลผลแล้ว
+	_, err = p.subscriptionRepository.UpdateEventSubscription(ctx, tx, eventHandler.GetSubscriptionName(), event.TransactionID, event.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update event subscription: %w", err)
+	}
 
-			// อัปเดต subscription ด้วยเหตุการณ์ล่าสุดที่ประมวลผลแล้ว
-			lastEvent := events[len(events)-1]
-			_, err = p.subscriptionRepository.UpdateEventSubscription(tx, eventHandler.GetSubscriptionName(), lastEvent.TransactionID, lastEvent.ID)
-			if err != nil {
-				return fmt.Errorf("failed to update event subscription: %w", err)
-			}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RedriveDeadLetters implements EventSubscriptionProcessor.
+// ประมวลผล event ใน dead letter ของ subscription ซ้ำตามลำดับเดิม โดยลบ event ที่สำเร็จออกจาก dead letter
+// และหยุดที่ event แรกที่ยังล้มเหลว ซึ่งจะถูกบันทึกจำนวนครั้งและ error ล่าสุดไว้ใน dead letter
+// คืน core.ErrSubscriptionLocked หาก subscription ถูกล็อกโดย process อื่นหรือถูก pause อยู่
+func (p *eventSubscriptionProcessor) RedriveDeadLetters(ctx context.Context, eventHandler core.AsyncEventHandler) (int, error) {
+	redriven := 0
+	for ctx.Err() == nil {
+		ok, err := p.redriveDeadLetter(ctx, eventHandler)
+		if err != nil || !ok {
+			return redriven, err
+		}
+		redriven++
+	}
+	return redriven, ctx.Err()
+}
+
+// redriveDeadLetter ล็อก subscription และประมวลผล event แรกใน dead letter ซ้ำ
+// คืน false หากไม่มี event เหลือใน dead letter
+func (p *eventSubscriptionProcessor) redriveDeadLetter(ctx context.Context, eventHandler core.AsyncEventHandler) (bool, error) {
+	tx, checkpoint, err := p.subscriptionRepository.ReadCheckpointAndLockSubscription(ctx, eventHandler.GetSubscriptionName())
+	if err != nil {
+		return false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	defer tx.Rollback()
+
+	if checkpoint == nil {
+		return false, core.ErrSubscriptionLocked
+	}
+
+	events, err := p.subscriptionRepository.ReadDeadLetterEvents(ctx, tx, eventHandler.GetSubscriptionName(), 1)
+	if err != nil || len(events) == 0 {
+		return false, err
+	}
+	event := events[0]
+
+	if attempts, err := p.handleWithRetry(ctx, ctx, eventHandler, event); err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if saveErr := p.subscriptionRepository.SaveDeadLetter(ctx, tx, eventHandler.GetSubscriptionName(), event, attempts, err.Error()); saveErr != nil {
+			return false, saveErr
 		}
+		if commitErr := tx.Commit(); commitErr != nil {
+			return false, commitErr
+		}
+		return false, fmt.Errorf("failed to redrive event %d after %d attempt(s): %w", event.ID, attempts, err)
+	}
+
+	helper.Println(fmt.Sprintf("Redrove event %d of subscription %s from dead letter", event.ID, eventHandler.GetSubscriptionName()))
+	if err := p.subscriptionRepository.DeleteDeadLetter(ctx, tx, eventHandler.GetSubscriptionName(), event.ID); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
 	}
+	return true, nil
+}
 
-	return tx.Commit()
+// handleWithRetry เรียก handler ซ้ำตาม retryPolicy และคืนจำนวนครั้งที่เรียกพร้อม error ครั้งล่าสุดของ handler
+// การรอ backoff ระหว่างแต่ละครั้งหยุดเมื่อ stop ถูกยกเลิก
+func (p *eventSubscriptionProcessor) handleWithRetry(ctx context.Context, stop context.Context, eventHandler core.AsyncEventHandler, event core.Event) (int, error) {
+	attempts := 0
+	var lastErr error
+	err := p.retryPolicy.Do(stop, func() error {
+		attempts++
+		lastErr = eventHandler.HandleEvent(ctx, event)
+		return lastErr
+	})
+	if err != nil && lastErr != nil {
+		return attempts, lastErr
+	}
+	return attempts, err
 }
@@ -0,0 +1,108 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
+)
+
+type orderIntegrationEventMapper struct{}
+
+// GetAggregateType implements IntegrationEventMapper.
+func (m orderIntegrationEventMapper) GetAggregateType() string {
+	orderAggregate := order.OrderAggregate{}
+	return orderAggregate.GetAggregateType()
+}
+
+// MapEvents implements IntegrationEventMapper.
+// แปลง domain event ของ order เป็น integration event ใน package contracts และระบุ schema version ใน header
+// message ใช้ order ID เป็น key เพื่อให้ event ของ order เดียวกันอยู่ใน partition เดียวกันและคงลำดับไว้
+func (m orderIntegrationEventMapper) MapEvents(aggregate core.Aggregate, events []core.Event) ([]core.OutboxMessage, error) {
+	orderAggregate := aggregate.(*order.OrderAggregate)
+
+	messages := make([]core.OutboxMessage, 0, len(events))
+	for _, event := range events {
+		integrationEvent, err := m.toIntegrationEvent(orderAggregate, event)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := json.Marshal(integrationEvent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %s: %w", integrationEvent.EventType(), err)
+		}
+
+		message := core.NewOutboxMessage(aggregate, event, contracts.TOPIC_ORDER_EVENT, event.AggregateID.String(), payload)
+		for key, value := range contracts.Headers(integrationEvent) {
+			message.Headers[key] = value
+		}
+		message.Headers[contracts.HEADER_AGGREGATE_ID] = event.AggregateID.String()
+		message.Headers[contracts.HEADER_EVENT_ID] = strconv.FormatInt(event.ID, 10)
+		message.Headers[contracts.HEADER_EVENT_VERSION] = strconv.Itoa(event.Version)
+		message.Headers[contracts.HEADER_OCCURRED_AT] = event.CreatedAt.UTC().Format(time.RFC3339Nano)
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+func (m orderIntegrationEventMapper) toIntegrationEvent(orderAggregate *order.OrderAggregate, event core.Event) (contracts.IntegrationEvent, error) {
+	orderID := event.AggregateID.String()
+	switch eventData := event.EventData.(type) {
+	case order.OrderCreatedEvent:
+		return contracts.OrderCreatedV1{
+			OrderID:    orderID,
+			Name:       eventData.Name,
+			Lines:      toOrderLines(eventData.OrderItems),
+			OccurredAt: event.CreatedAt,
+		}, nil
+	case order.OrderUpdatedEvent:
+		return contracts.OrderUpdatedV1{
+			OrderID:    orderID,
+			Name:       eventData.Name,
+			Lines:      toOrderLines(eventData.OrderItems),
+			OccurredAt: event.CreatedAt,
+		}, nil
+	case order.OrderItemAmountUpdatedEvent:
+		return contracts.OrderLineQuantityChangedV1{
+			OrderID:    orderID,
+			ItemID:     eventData.ID.String(),
+			Quantity:   eventData.Amount,
+			OccurredAt: event.CreatedAt,
+		}, nil
+	case order.OrderSubmittedEvent:
+		// OrderSubmittedEvent ไม่มีรายการสินค้า จึงใช้รายการสินค้าของ order ขณะ submit
+		return contracts.OrderPlacedV1{
+			OrderID:    orderID,
+			Lines:      toOrderLines(orderAggregate.OrderItems),
+			OccurredAt: event.CreatedAt,
+		}, nil
+	case order.OrderCancelledEvent:
+		return contracts.OrderCancelledV1{
+			OrderID:    orderID,
+			Reason:     eventData.Reason,
+			OccurredAt: event.CreatedAt,
+		}, nil
+	default:
+		return nil, fmt.Errorf("no integration event for %s", event.EventType)
+	}
+}
+
+func toOrderLines(orderItems []order.OrderItem) []contracts.OrderLine {
+	lines := make([]contracts.OrderLine, 0, len(orderItems))
+	for _, item := range orderItems {
+		lines = append(lines, contracts.OrderLine{
+			ItemID:   item.ID.String(),
+			Name:     item.Name,
+			Quantity: item.Amount,
+		})
+	}
+	return lines
+}
+
+func NewOrderIntegrationEventMapper() IntegrationEventMapper {
+	return orderIntegrationEventMapper{}
+}
@@ -1,12 +1,17 @@
 package application
 
 import (
+	"context"
+
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
 )
 
 type OrderProjection interface {
-	core.SyncEventHandler
+	core.TransactionalSyncEventHandler
+	core.VersionedSyncEventHandler
 }
 
 type orderProjection struct {
@@ -20,12 +25,23 @@ func (o *orderProjection) GetAggregateType() string {
 }
 
 // HandleEvent implements core.SyncEventHandler.
-func (o *orderProjection) HandleEvent(aggregate core.Aggregate) error {
+func (o *orderProjection) HandleEvent(ctx context.Context, aggregate core.Aggregate) error {
+	orderAggregate := aggregate.(*order.OrderAggregate)
+	return o.orderRepository.SaveOrder(ctx, orderAggregate)
+}
+
+// HandleEventTx implements core.TransactionalSyncEventHandler.
+func (o *orderProjection) HandleEventTx(ctx context.Context, tx *sqlx.Tx, aggregate core.Aggregate) error {
 	orderAggregate := aggregate.(*order.OrderAggregate)
-	return o.orderRepository.SaveOrder(orderAggregate)
+	return o.orderRepository.SaveOrderTx(ctx, tx, orderAggregate)
+}
+
+// GetProjectedVersion implements core.VersionedSyncEventHandler.
+func (o *orderProjection) GetProjectedVersion(ctx context.Context, aggregateID uuid.UUID) (int, error) {
+	return o.orderRepository.GetOrderVersion(ctx, aggregateID)
 }
 
-func NewOrderProjection(orderRepository order.QueryOrderRepository) core.SyncEventHandler {
+func NewOrderProjection(orderRepository order.QueryOrderRepository) OrderProjection {
 	return &orderProjection{
 		orderRepository: orderRepository,
 	}
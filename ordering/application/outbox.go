@@ -0,0 +1,48 @@
+package application
+
+import (
+	"context"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/jmoiron/sqlx"
+)
+
+// IntegrationEventMapper แปลง domain event ของ aggregate เป็น integration message ที่จะส่งออกนอก service
+type IntegrationEventMapper interface {
+	GetAggregateType() string
+	MapEvents(aggregate core.Aggregate, events []core.Event) ([]core.OutboxMessage, error)
+}
+
+// Outbox บันทึก integration message ของ event ลงตาราง outbox ภายใน transaction เดียวกับ event store
+type Outbox interface {
+	AddTx(ctx context.Context, tx *sqlx.Tx, aggregate core.Aggregate, events []core.Event) error
+}
+
+type outbox struct {
+	outboxRepository core.OutboxRepository
+	mappers          []IntegrationEventMapper
+}
+
+func NewOutbox(outboxRepository core.OutboxRepository, mappers ...IntegrationEventMapper) Outbox {
+	return &outbox{
+		outboxRepository: outboxRepository,
+		mappers:          mappers,
+	}
+}
+
+// AddTx implements Outbox.
+func (o *outbox) AddTx(ctx context.Context, tx *sqlx.Tx, aggregate core.Aggregate, events []core.Event) error {
+	for _, mapper := range o.mappers {
+		if mapper.GetAggregateType() != aggregate.GetAggregateType() {
+			continue
+		}
+		messages, err := mapper.MapEvents(aggregate, events)
+		if err != nil {
+			return err
+		}
+		if err := o.outboxRepository.SaveTx(ctx, tx, messages); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package application
+
+import (
+	"context"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+)
+
+// LegacyIntegrationEventSenderSubscription คือชื่อ subscription ที่ส่ง integration event ไปยัง Kafka โดยตรงก่อนมี outbox
+const LegacyIntegrationEventSenderSubscription = "OrderIntegrationEventSender"
+
+// OutboxBackfill บันทึก integration message ของ event ที่ถูกบันทึกก่อนมี outbox ลงตาราง outbox
+// โดยทำงานต่อจาก checkpoint ของ subscription เดิมที่ส่ง event ไปยัง Kafka โดยตรง
+// event ที่มี ID มากกว่า cutoverEventID ถูกบันทึกลง outbox พร้อมกับ event แล้วจึงถูกข้าม
+// เพื่อไม่ให้ message ที่ถูกส่งและลบออกจาก outbox ตาม retention แล้วถูกบันทึกและส่งซ้ำ
+type OutboxBackfill struct {
+	subscriptionName string
+	cutoverEventID   int64
+	eventStore       core.EventStore
+	outbox           Outbox
+	newAggregate     func() core.Aggregate
+	aggregateLoader  core.AggregateLoader
+}
+
+// GetAggregateType implements core.AsyncEventHandler.
+func (b OutboxBackfill) GetAggregateType() string {
+	return b.newAggregate().GetAggregateType()
+}
+
+// GetSubscriptionName implements core.AsyncEventHandler.
+func (b OutboxBackfill) GetSubscriptionName() string {
+	return b.subscriptionName
+}
+
+// HandleEvent implements core.AsyncEventHandler.
+// โหลด aggregate ณ version ของ event เพื่อสร้าง message เหมือนตอนที่ event ถูกบันทึก
+func (b OutboxBackfill) HandleEvent(ctx context.Context, event core.Event) error {
+	if event.ID > b.cutoverEventID {
+		return nil
+	}
+
+	aggregate := b.newAggregate()
+	if err := b.aggregateLoader.LoadInto(ctx, aggregate, event.AggregateID, &event.Version); err != nil {
+		return err
+	}
+
+	tx, err := b.eventStore.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := b.outbox.AddTx(ctx, tx, aggregate, []core.Event{event}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func NewOutboxBackfill(subscriptionName string, cutoverEventID int64, eventStore core.EventStore, outbox Outbox, newAggregate func() core.Aggregate, aggregateLoader core.AggregateLoader) core.AsyncEventHandler {
+	return OutboxBackfill{
+		subscriptionName: subscriptionName,
+		cutoverEventID:   cutoverEventID,
+		eventStore:       eventStore,
+		outbox:           outbox,
+		newAggregate:     newAggregate,
+		aggregateLoader:  aggregateLoader,
+	}
+}
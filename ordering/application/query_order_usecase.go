@@ -1,11 +1,15 @@
 package application
 
 import (
+	"context"
+
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
+	"github.com/gofrs/uuid"
 )
 
 type QueryOrderUsecase interface {
-	GetOrders() ([]order.Order, error)
+	GetOrders(ctx context.Context) ([]order.Order, error)
+	GetOrder(ctx context.Context, id uuid.UUID) (*order.Order, error)
 }
 
 type queryOrderUsecase struct {
@@ -13,8 +17,13 @@ type queryOrderUsecase struct {
 }
 
 // GetOrders implements QueryOrderUsecase.
-func (q *queryOrderUsecase) GetOrders() ([]order.Order, error) {
-	return q.orderRepository.GetOrders()
+func (q *queryOrderUsecase) GetOrders(ctx context.Context) ([]order.Order, error) {
+	return q.orderRepository.GetOrders(ctx)
+}
+
+// GetOrder implements QueryOrderUsecase.
+func (q *queryOrderUsecase) GetOrder(ctx context.Context, id uuid.UUID) (*order.Order, error) {
+	return q.orderRepository.GetOrder(ctx, id)
 }
 
 func NewQueryOrderUsecase(orderRepository order.QueryOrderRepository) QueryOrderUsecase {
@@ -0,0 +1,80 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+)
+
+var ErrConcurrencyConflict = errors.New("concurrency conflict")
+
+// RetryPolicy ใช้ทำซ้ำ command เมื่อเกิด optimistic concurrency conflict
+// โดยรอแบบ exponential backoff พร้อม jitter และหยุดเมื่อ context ถูกยกเลิก
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// Retryable ตัดสินว่า error ใดควรทำซ้ำ ถ้าไม่กำหนดจะทำซ้ำเฉพาะ core.ErrAggregateOutdated
+	Retryable func(err error) bool
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     500 * time.Millisecond,
+		Multiplier:     2,
+	}
+}
+
+// Do เรียก fn จนกว่าจะสำเร็จ เจอ error ที่ไม่ควรทำซ้ำ หรือครบ MaxAttempts
+// เมื่อครบจำนวนครั้งแล้วยังไม่สำเร็จจะคืน ErrConcurrencyConflict
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !p.isRetryable(err) {
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return fmt.Errorf("%w: giving up after %d attempts: %v", ErrConcurrencyConflict, attempt, err)
+		}
+
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (p RetryPolicy) isRetryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return errors.Is(err, core.ErrAggregateOutdated)
+}
+
+// backoff คำนวณเวลารอก่อนทำซ้ำครั้งถัดไปแบบ equal jitter
+// ใช้สูตรเดียวกับ ErrorPolicy.backoff ของ inventory service หากแก้ไขต้องแก้ทั้งสองที่ให้ตรงกัน
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		backoff *= p.Multiplier
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+
+	half := int64(backoff / 2)
+	if half <= 0 {
+		return time.Duration(backoff)
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
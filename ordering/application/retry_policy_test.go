@@ -0,0 +1,129 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	errPermanent := errors.New("permanent")
+	policy := RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     time.Millisecond,
+		Multiplier:     2,
+	}
+
+	tests := []struct {
+		name         string
+		policy       RetryPolicy
+		errs         []error
+		cancel       bool
+		wantAttempts int
+		wantErr      error
+	}{
+		{
+			name:         "success on first attempt",
+			policy:       policy,
+			errs:         []error{nil},
+			wantAttempts: 1,
+		},
+		{
+			name:         "outdated aggregate is retried until success",
+			policy:       policy,
+			errs:         []error{core.ErrAggregateOutdated, fmt.Errorf("append: %w", core.ErrAggregateOutdated), nil},
+			wantAttempts: 3,
+		},
+		{
+			name:         "outdated aggregate gives up after max attempts",
+			policy:       policy,
+			errs:         []error{core.ErrAggregateOutdated, core.ErrAggregateOutdated, core.ErrAggregateOutdated, nil},
+			wantAttempts: 3,
+			wantErr:      ErrConcurrencyConflict,
+		},
+		{
+			name:         "other error is not retried",
+			policy:       policy,
+			errs:         []error{errPermanent, nil},
+			wantAttempts: 1,
+			wantErr:      errPermanent,
+		},
+		{
+			name: "custom retryable predicate",
+			policy: RetryPolicy{
+				MaxAttempts:    3,
+				InitialBackoff: time.Millisecond,
+				Multiplier:     2,
+				Retryable:      func(err error) bool { return errors.Is(err, errPermanent) },
+			},
+			errs:         []error{errPermanent, core.ErrAggregateOutdated},
+			wantAttempts: 2,
+			wantErr:      core.ErrAggregateOutdated,
+		},
+		{
+			name:         "cancelled context stops waiting for the next attempt",
+			policy:       RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour, Multiplier: 2},
+			errs:         []error{core.ErrAggregateOutdated, nil},
+			cancel:       true,
+			wantAttempts: 1,
+			wantErr:      context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			attempts := 0
+			err := tt.policy.Do(ctx, func() error {
+				err := tt.errs[attempts]
+				attempts++
+				if tt.cancel {
+					cancel()
+				}
+				return err
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Do() error = %v, want %v", err, tt.wantErr)
+			}
+			if attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", attempts, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+		Multiplier:     2,
+	}
+
+	tests := []struct {
+		name    string
+		attempt int
+		wantMin time.Duration
+		wantMax time.Duration
+	}{
+		{name: "first attempt waits half to full initial backoff", attempt: 1, wantMin: 50 * time.Millisecond, wantMax: 100 * time.Millisecond},
+		{name: "second attempt doubles the backoff", attempt: 2, wantMin: 100 * time.Millisecond, wantMax: 200 * time.Millisecond},
+		{name: "backoff is capped at max backoff", attempt: 5, wantMin: 150 * time.Millisecond, wantMax: 300 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := policy.backoff(tt.attempt); got < tt.wantMin || got > tt.wantMax {
+					t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, got, tt.wantMin, tt.wantMax)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+)
+
+// SubscriptionInfo รวมสถานะของ subscription ใน supervisor กับตำแหน่งและ lag ใน es_event_subscription
+type SubscriptionInfo struct {
+	SubscriptionStatus
+	LastTransactionID int64   `json:"last_transaction_id"`
+	LastEventID       int64   `json:"last_event_id"`
+	Paused            bool    `json:"paused"`
+	LagEvents         int64   `json:"lag_events"`
+	LagSeconds        float64 `json:"lag_seconds"`
+}
+
+// SubscriptionAdminUsecase ใช้ตรวจสอบและควบคุม subscription ที่ลงทะเบียนกับ SubscriptionSupervisor
+type SubscriptionAdminUsecase interface {
+	ListSubscriptions(ctx context.Context) ([]SubscriptionInfo, error)
+	PauseSubscription(ctx context.Context, subscriptionName string) error
+	ResumeSubscription(ctx context.Context, subscriptionName string) error
+	ResetSubscriptionToEvent(ctx context.Context, subscriptionName string, eventID int64) error
+	ResetSubscriptionToTime(ctx context.Context, subscriptionName string, at time.Time) error
+	ListDeadLetters(ctx context.Context, subscriptionName string) ([]core.EventDeadLetter, error)
+	RedriveDeadLetters(ctx context.Context, subscriptionName string) (int, error)
+}
+
+type subscriptionAdminUsecase struct {
+	subscriptionSupervisor SubscriptionSupervisor
+	subscriptionRepository core.EventSubscriptionRepository
+}
+
+func NewSubscriptionAdminUsecase(subscriptionSupervisor SubscriptionSupervisor, subscriptionRepository core.EventSubscriptionRepository) SubscriptionAdminUsecase {
+	return &subscriptionAdminUsecase{
+		subscriptionSupervisor: subscriptionSupervisor,
+		subscriptionRepository: subscriptionRepository,
+	}
+}
+
+// ListSubscriptions implements SubscriptionAdminUsecase.
+func (u *subscriptionAdminUsecase) ListSubscriptions(ctx context.Context) ([]SubscriptionInfo, error) {
+	statuses := u.subscriptionSupervisor.Statuses()
+	subscriptions := make([]SubscriptionInfo, 0, len(statuses))
+	for _, status := range statuses {
+		subscription := SubscriptionInfo{SubscriptionStatus: status}
+		info, err := u.subscriptionRepository.GetSubscriptionInfo(ctx, status.SubscriptionName, status.AggregateType)
+		if err != nil && !errors.Is(err, core.ErrSubscriptionNotFound) {
+			return nil, err
+		}
+		// subscription ที่ยังไม่เคยประมวลผลจะยังไม่มีแถวใน es_event_subscription
+		if info != nil {
+			subscription.LastTransactionID = info.LasttransactionID
+			subscription.LastEventID = info.LastEventID
+			subscription.Paused = info.Paused
+			subscription.LagEvents = info.LagEvents
+			subscription.LagSeconds = info.LagSeconds
+		}
+		subscriptions = append(subscriptions, subscription)
+	}
+	return subscriptions, nil
+}
+
+// PauseSubscription implements SubscriptionAdminUsecase.
+func (u *subscriptionAdminUsecase) PauseSubscription(ctx context.Context, subscriptionName string) error {
+	if _, err := u.findStatus(subscriptionName); err != nil {
+		return err
+	}
+	return u.subscriptionRepository.SetSubscriptionPaused(ctx, subscriptionName, true)
+}
+
+// ResumeSubscription implements SubscriptionAdminUsecase.
+func (u *subscriptionAdminUsecase) ResumeSubscription(ctx context.Context, subscriptionName string) error {
+	if _, err := u.findStatus(subscriptionName); err != nil {
+		return err
+	}
+	return u.subscriptionRepository.SetSubscriptionPaused(ctx, subscriptionName, false)
+}
+
+// ResetSubscriptionToEvent implements SubscriptionAdminUsecase.
+func (u *subscriptionAdminUsecase) ResetSubscriptionToEvent(ctx context.Context, subscriptionName string, eventID int64) error {
+	status, err := u.findStatus(subscriptionName)
+	if err != nil {
+		return err
+	}
+	return u.subscriptionRepository.ResetSubscriptionToEvent(ctx, subscriptionName, status.AggregateType, eventID)
+}
+
+// ResetSubscriptionToTime implements SubscriptionAdminUsecase.
+func (u *subscriptionAdminUsecase) ResetSubscriptionToTime(ctx context.Context, subscriptionName string, at time.Time) error {
+	status, err := u.findStatus(subscriptionName)
+	if err != nil {
+		return err
+	}
+	return u.subscriptionRepository.ResetSubscriptionToTime(ctx, subscriptionName, status.AggregateType, at)
+}
+
+// ListDeadLetters implements SubscriptionAdminUsecase.
+func (u *subscriptionAdminUsecase) ListDeadLetters(ctx context.Context, subscriptionName string) ([]core.EventDeadLetter, error) {
+	if _, err := u.findStatus(subscriptionName); err != nil {
+		return nil, err
+	}
+	return u.subscriptionRepository.ListDeadLetters(ctx, subscriptionName)
+}
+
+// RedriveDeadLetters implements SubscriptionAdminUsecase.
+func (u *subscriptionAdminUsecase) RedriveDeadLetters(ctx context.Context, subscriptionName string) (int, error) {
+	return u.subscriptionSupervisor.RedriveDeadLetters(ctx, subscriptionName)
+}
+
+// findStatus คืนสถานะของ subscription ที่ลงทะเบียนกับ supervisor
+func (u *subscriptionAdminUsecase) findStatus(subscriptionName string) (*SubscriptionStatus, error) {
+	for _, status := range u.subscriptionSupervisor.Statuses() {
+		if status.SubscriptionName == subscriptionName {
+			return &status, nil
+		}
+	}
+	return nil, core.ErrSubscriptionNotFound
+}
@@ -0,0 +1,263 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/helper"
+)
+
+type SubscriptionState string
+
+const (
+	SubscriptionStateStarting   SubscriptionState = "STARTING"
+	SubscriptionStateRunning    SubscriptionState = "RUNNING"
+	SubscriptionStateRestarting SubscriptionState = "RESTARTING"
+	SubscriptionStateStopped    SubscriptionState = "STOPPED"
+)
+
+// SubscriptionStatus คือสถานะของ subscription หนึ่งตัวที่ supervisor ดูแลอยู่
+type SubscriptionStatus struct {
+	SubscriptionName string            `json:"subscription_name"`
+	AggregateType    string            `json:"aggregate_type"`
+	State            SubscriptionState `json:"state"`
+	Restarts         int               `json:"restarts"`
+	ProcessedEvents  int64             `json:"processed_events"`
+	LastProcessedAt  *time.Time        `json:"last_processed_at,omitempty"`
+	LastError        string            `json:"last_error,omitempty"`
+	LastErrorAt      *time.Time        `json:"last_error_at,omitempty"`
+}
+
+// SubscriptionSupervisor รัน async event handler แต่ละตัวใน goroutine ของตัวเอง
+// โดยแต่ละ subscription มี checkpoint แยกกันใน es_event_subscription
+// และจะเริ่ม handler ใหม่หากเกิด panic
+// subscription จะถูกปลุกทันทีเมื่อ notifier แจ้งว่ามี event ใหม่ และ poll ทุก pollInterval เป็น fallback
+type SubscriptionSupervisor interface {
+	Register(eventHandler core.AsyncEventHandler)
+	Run(ctx context.Context)
+	Statuses() []SubscriptionStatus
+	RedriveDeadLetters(ctx context.Context, subscriptionName string) (int, error)
+}
+
+type supervisedSubscription struct {
+	eventHandler core.AsyncEventHandler
+	status       SubscriptionStatus
+}
+
+type subscriptionSupervisor struct {
+	processor         EventSubscriptionProcessor
+	notifier          core.EventNotifier
+	pollInterval      time.Duration
+	maxRestartBackoff time.Duration
+
+	mu            sync.RWMutex
+	subscriptions map[string]*supervisedSubscription
+}
+
+func NewSubscriptionSupervisor(processor EventSubscriptionProcessor, notifier core.EventNotifier, pollInterval time.Duration) SubscriptionSupervisor {
+	return &subscriptionSupervisor{
+		processor:         processor,
+		notifier:          notifier,
+		pollInterval:      pollInterval,
+		maxRestartBackoff: 30 * time.Second,
+		subscriptions:     make(map[string]*supervisedSubscription),
+	}
+}
+
+// Register implements SubscriptionSupervisor.
+func (s *subscriptionSupervisor) Register(eventHandler core.AsyncEventHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := eventHandler.GetSubscriptionName()
+	if _, ok := s.subscriptions[name]; ok {
+		panic(fmt.Sprintf("subscription %s is already registered", name))
+	}
+	s.subscriptions[name] = &supervisedSubscription{
+		eventHandler: eventHandler,
+		status: SubscriptionStatus{
+			SubscriptionName: name,
+			AggregateType:    eventHandler.GetAggregateType(),
+			State:            SubscriptionStateStarting,
+		},
+	}
+}
+
+// Run implements SubscriptionSupervisor.
+// Run จะ block จนกว่า ctx ถูกยกเลิกและทุก subscription หยุดหลังจาก batch ปัจจุบัน commit แล้ว
+func (s *subscriptionSupervisor) Run(ctx context.Context) {
+	s.mu.RLock()
+	names := make([]string, 0, len(s.subscriptions))
+	for name := range s.subscriptions {
+		names = append(names, name)
+	}
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			s.supervise(ctx, name)
+		}(name)
+	}
+	wg.Wait()
+}
+
+// Statuses implements SubscriptionSupervisor.
+func (s *subscriptionSupervisor) Statuses() []SubscriptionStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	statuses := make([]SubscriptionStatus, 0, len(s.subscriptions))
+	for _, subscription := range s.subscriptions {
+		statuses = append(statuses, subscription.status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].SubscriptionName < statuses[j].SubscriptionName
+	})
+	return statuses
+}
+
+// RedriveDeadLetters implements SubscriptionSupervisor.
+// ประมวลผล event ใน dead letter ซ้ำด้วย handler ที่ลงทะเบียนไว้ในชื่อ subscriptionName
+func (s *subscriptionSupervisor) RedriveDeadLetters(ctx context.Context, subscriptionName string) (int, error) {
+	s.mu.RLock()
+	subscription, ok := s.subscriptions[subscriptionName]
+	s.mu.RUnlock()
+	if !ok {
+		return 0, core.ErrSubscriptionNotFound
+	}
+	return s.processor.RedriveDeadLetters(ctx, subscription.eventHandler)
+}
+
+// supervise เริ่ม subscription ใหม่ทุกครั้งที่เกิด panic โดยรอแบบ exponential backoff
+func (s *subscriptionSupervisor) supervise(ctx context.Context, name string) {
+	defer s.setState(name, SubscriptionStateStopped)
+
+	wakeup := s.subscribeNotifications(name)
+
+	backoff := s.pollInterval
+	for {
+		s.setState(name, SubscriptionStateRunning)
+		startedAt := time.Now()
+		err := s.runSubscription(ctx, name, wakeup)
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		// ถ้าทำงานได้นานพอก่อน crash ให้เริ่ม backoff ใหม่
+		if time.Since(startedAt) > s.maxRestartBackoff {
+			backoff = s.pollInterval
+		}
+
+		log.Printf("Subscription %s crashed, restarting in %s: %v", name, backoff, err)
+		s.update(name, func(status *SubscriptionStatus) {
+			now := time.Now()
+			status.State = SubscriptionStateRestarting
+			status.Restarts++
+			status.LastError = err.Error()
+			status.LastErrorAt = &now
+		})
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > s.maxRestartBackoff {
+			backoff = s.maxRestartBackoff
+		}
+	}
+}
+
+// subscribeNotifications คืน channel ที่ถูกปลุกเมื่อมี event ใหม่ หรือ nil หากไม่มี notifier
+// ซึ่งทำให้ subscription ใช้การ poll เพียงอย่างเดียว
+func (s *subscriptionSupervisor) subscribeNotifications(name string) <-chan struct{} {
+	if s.notifier == nil {
+		return nil
+	}
+	s.mu.RLock()
+	aggregateType := s.subscriptions[name].eventHandler.GetAggregateType()
+	s.mu.RUnlock()
+
+	wakeup, err := s.notifier.Subscribe(aggregateType)
+	if err != nil {
+		log.Printf("Subscription %s falls back to polling every %s: %v", name, s.pollInterval, err)
+		return nil
+	}
+	return wakeup
+}
+
+// runSubscription ประมวลผลเหตุการณ์ใหม่เมื่อถูกปลุกหรือครบ pollInterval จนกว่า ctx ถูกยกเลิก
+// และแปลง panic ของ handler เป็น error
+func (s *subscriptionSupervisor) runSubscription(ctx context.Context, name string, wakeup <-chan struct{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			debug.PrintStack()
+			err = fmt.Errorf("subscription %s panicked: %v", name, r)
+		}
+	}()
+
+	s.mu.RLock()
+	eventHandler := s.subscriptions[name].eventHandler
+	s.mu.RUnlock()
+
+	// ตามเก็บ event ที่ค้างอยู่ก่อนเริ่มรอการแจ้งเตือน
+	s.catchUp(ctx, name, eventHandler)
+
+	// Polling every pollInterval as a fallback for missed notifications
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wakeup:
+			s.catchUp(ctx, name, eventHandler)
+		case <-ticker.C:
+			s.catchUp(ctx, name, eventHandler)
+		}
+	}
+}
+
+func (s *subscriptionSupervisor) catchUp(ctx context.Context, name string, eventHandler core.AsyncEventHandler) {
+	processed, err := s.processor.CatchUp(ctx, eventHandler)
+	s.recordBatch(name, processed, err)
+	if err != nil {
+		helper.Println(fmt.Sprintf("Error processing new events: %v", err))
+	}
+}
+
+func (s *subscriptionSupervisor) recordBatch(name string, processed int, err error) {
+	s.update(name, func(status *SubscriptionStatus) {
+		now := time.Now()
+		if err != nil {
+			status.LastError = err.Error()
+			status.LastErrorAt = &now
+			return
+		}
+		if processed > 0 {
+			status.ProcessedEvents += int64(processed)
+			status.LastProcessedAt = &now
+		}
+	})
+}
+
+func (s *subscriptionSupervisor) setState(name string, state SubscriptionState) {
+	s.update(name, func(status *SubscriptionStatus) {
+		status.State = state
+	})
+}
+
+func (s *subscriptionSupervisor) update(name string, fn func(status *SubscriptionStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if subscription, ok := s.subscriptions[name]; ok {
+		fn(&subscription.status)
+	}
+}
@@ -0,0 +1,55 @@
+package application
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+)
+
+// SyncEventHandlerSubscription นำ core.SyncEventHandler มาทำงานผ่าน async subscription
+// เพื่อทำซ้ำ projection ที่ล้มเหลวหลัง commit ด้วย policy SyncHandlerAsyncFallback
+// และข้าม event ที่ projection ถึง version นั้นแล้ว เช่นเมื่อใช้ policy SyncHandlerInTransaction
+type SyncEventHandlerSubscription struct {
+	handler         core.SyncEventHandler
+	newAggregate    func() core.Aggregate
+	aggregateLoader core.AggregateLoader
+}
+
+// GetAggregateType implements core.AsyncEventHandler.
+func (s SyncEventHandlerSubscription) GetAggregateType() string {
+	return s.handler.GetAggregateType()
+}
+
+// GetSubscriptionName implements core.AsyncEventHandler.
+func (s SyncEventHandlerSubscription) GetSubscriptionName() string {
+	return reflect.Indirect(reflect.ValueOf(s.handler)).Type().Name() + "AsyncFallback"
+}
+
+// HandleEvent implements core.AsyncEventHandler.
+func (s SyncEventHandlerSubscription) HandleEvent(ctx context.Context, event core.Event) error {
+	if versioned, ok := s.handler.(core.VersionedSyncEventHandler); ok {
+		projectedVersion, err := versioned.GetProjectedVersion(ctx, event.AggregateID)
+		if err != nil {
+			return err
+		}
+		if projectedVersion >= event.Version {
+			return nil
+		}
+	}
+
+	aggregate := s.newAggregate()
+	if err := s.aggregateLoader.LoadInto(ctx, aggregate, event.AggregateID, &event.Version); err != nil {
+		return err
+	}
+
+	return s.handler.HandleEvent(ctx, aggregate)
+}
+
+func NewSyncEventHandlerSubscription(handler core.SyncEventHandler, newAggregate func() core.Aggregate, aggregateLoader core.AggregateLoader) core.AsyncEventHandler {
+	return SyncEventHandlerSubscription{
+		handler:         handler,
+		newAggregate:    newAggregate,
+		aggregateLoader: aggregateLoader,
+	}
+}
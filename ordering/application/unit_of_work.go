@@ -0,0 +1,124 @@
+package application
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/helper"
+)
+
+// SyncHandlerPolicy กำหนดวิธีเรียก core.SyncEventHandler เมื่อ commit unit of work
+type SyncHandlerPolicy string
+
+const (
+	// SyncHandlerInTransaction เรียก handler ภายใน transaction เดียวกับ event store
+	// ใช้ได้เมื่อ read model อยู่ใน database เดียวกับ event store
+	SyncHandlerInTransaction SyncHandlerPolicy = "in_transaction"
+	// SyncHandlerAsyncFallback เรียก handler หลังจาก commit event แล้ว หาก handler ล้มเหลว
+	// จะไม่คืน error ให้ command แต่ปล่อยให้ async subscription ของ handler นั้นทำซ้ำแทน
+	SyncHandlerAsyncFallback SyncHandlerPolicy = "async_fallback"
+)
+
+// UnitOfWork เก็บ event ที่รอบันทึกของ aggregate และ commit พร้อมกับเรียก sync handler ตาม policy
+// integration message ของ event จะถูกบันทึกลง outbox ใน transaction เดียวกับ event เสมอ
+type UnitOfWork interface {
+	Track(aggregate core.Aggregate, expectedVersion int, events []core.Event)
+	Commit(ctx context.Context) error
+}
+
+// UnitOfWorkFactory สร้าง UnitOfWork ใหม่สำหรับแต่ละ command
+type UnitOfWorkFactory func() UnitOfWork
+
+type pendingAggregate struct {
+	aggregate       core.Aggregate
+	expectedVersion int
+	events          []core.Event
+}
+
+type unitOfWork struct {
+	eventStore   core.EventStore
+	outbox       Outbox
+	syncHandlers []core.SyncEventHandler
+	policy       SyncHandlerPolicy
+	pending      []pendingAggregate
+}
+
+func NewUnitOfWorkFactory(eventStore core.EventStore, outbox Outbox, policy SyncHandlerPolicy, syncHandlers ...core.SyncEventHandler) UnitOfWorkFactory {
+	return func() UnitOfWork {
+		return &unitOfWork{
+			eventStore:   eventStore,
+			outbox:       outbox,
+			syncHandlers: syncHandlers,
+			policy:       policy,
+		}
+	}
+}
+
+// Track implements UnitOfWork.
+func (u *unitOfWork) Track(aggregate core.Aggregate, expectedVersion int, events []core.Event) {
+	u.pending = append(u.pending, pendingAggregate{
+		aggregate:       aggregate,
+		expectedVersion: expectedVersion,
+		events:          events,
+	})
+}
+
+// Commit implements UnitOfWork.
+func (u *unitOfWork) Commit(ctx context.Context) error {
+	tx, err := u.eventStore.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range u.pending {
+		if err := u.eventStore.AppendTx(ctx, tx, p.aggregate, p.expectedVersion, p.events); err != nil {
+			return err
+		}
+		if err := u.outbox.AddTx(ctx, tx, p.aggregate, p.events); err != nil {
+			return fmt.Errorf("failed to add integration messages to outbox: %w", err)
+		}
+	}
+
+	// handler ที่ทำงานร่วม transaction ไม่ได้ จะถูกเรียกหลัง commit แบบ async fallback
+	var afterCommit []func() error
+	for _, p := range u.pending {
+		aggregate := p.aggregate
+		for _, handler := range u.handlersFor(aggregate) {
+			handler := handler
+			if txHandler, ok := handler.(core.TransactionalSyncEventHandler); ok && u.policy == SyncHandlerInTransaction {
+				if err := txHandler.HandleEventTx(ctx, tx, aggregate); err != nil {
+					return fmt.Errorf("failed to handle sync event in transaction: %w", err)
+				}
+				continue
+			}
+			afterCommit = append(afterCommit, func() error {
+				return handler.HandleEvent(ctx, aggregate)
+			})
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	u.pending = nil
+
+	for _, handle := range afterCommit {
+		if err := handle(); err != nil {
+			// event ถูกบันทึกแล้ว ให้ async subscription ของ handler ทำซ้ำแทนการคืน error
+			helper.Println(fmt.Sprintf("Sync event handler failed, deferring to async subscription: %v", err))
+		}
+	}
+	return nil
+}
+
+func (u *unitOfWork) handlersFor(aggregate core.Aggregate) []core.SyncEventHandler {
+	handlers := make([]core.SyncEventHandler, 0, len(u.syncHandlers))
+	for _, handler := range u.syncHandlers {
+		if handler.GetAggregateType() == aggregate.GetAggregateType() {
+			handlers = append(handlers, handler)
+		}
+	}
+	return handlers
+}
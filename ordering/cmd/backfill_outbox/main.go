@@ -0,0 +1,96 @@
+// backfill_outbox บันทึก integration message ของ event ที่ถูกบันทึกก่อนมีตาราง outbox ลง outbox
+//
+//	backfill_outbox
+//
+// ใช้ครั้งเดียวหลังจาก deploy ordering service ที่ใช้ outbox แทน subscription OrderIntegrationEventSender เดิม
+// โดยต้องหยุด ordering service รุ่นเดิมทั้งหมดก่อน migrate เพื่อให้ทุก event หลัง cutover ของ outbox_backfill ถูกบันทึกลง outbox
+// backfill ทำต่อจาก checkpoint ของ subscription เดิม event ก่อน cutover ที่ยังไม่ถูกส่งจึงถูกบันทึกลง outbox และถูกส่งโดย outbox relay
+// หากไม่มี checkpoint ของ subscription เดิม แสดงว่า event ทั้งหมดถูกบันทึกลง outbox แล้วและไม่มีอะไรต้องทำ
+// เมื่อ backfill เสร็จจะถูกบันทึกใน outbox_backfill และการรันครั้งถัดไปจะถูกปฏิเสธ
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/application"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/infrastructure/persistence/postgres"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/joho/godotenv/autoload"
+	_ "github.com/lib/pq"
+)
+
+var (
+	ORDER_EVENT_STORE = os.Getenv("ORDER_EVENT_STORE")
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	orderEventStoreDB, err := sqlx.Connect("postgres", ORDER_EVENT_STORE)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer orderEventStoreDB.Close()
+
+	eventRegistry := core.NewEventRegistry()
+	order.RegisterEvents(eventRegistry)
+
+	eventStore := postgres.NewEventStore(orderEventStoreDB)
+	eventRepo := postgres.NewEventRepository(orderEventStoreDB, eventRegistry)
+	aggregateRepo := postgres.NewAggregateRepository(orderEventStoreDB)
+	aggregateLoader := core.NewAggregateLoader(eventRepo, aggregateRepo)
+	subscriptionRepository := postgres.NewEventSubscriptionRepository(orderEventStoreDB, eventRegistry)
+	outboxRepository := postgres.NewOutboxRepository(orderEventStoreDB)
+	outbox := application.NewOutbox(outboxRepository, application.NewOrderIntegrationEventMapper())
+
+	cutoverEventID, completed, err := outboxRepository.GetBackfillCutover(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if completed {
+		log.Fatal("Outbox backfill has already completed, refusing to run again")
+	}
+
+	backfill := application.NewOutboxBackfill(application.LegacyIntegrationEventSenderSubscription, cutoverEventID, eventStore, outbox, func() core.Aggregate { return &order.OrderAggregate{} }, aggregateLoader)
+
+	info, err := subscriptionRepository.GetSubscriptionInfo(ctx, backfill.GetSubscriptionName(), backfill.GetAggregateType())
+	if errors.Is(err, core.ErrSubscriptionNotFound) {
+		log.Printf("Subscription %s does not exist, nothing to backfill", backfill.GetSubscriptionName())
+		if err := outboxRepository.CompleteBackfill(ctx); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Backfilling events up to %d after event %d of %s", cutoverEventID, info.LastEventID, backfill.GetSubscriptionName())
+
+	processor := application.NewEventSubscriptionProcessor(subscriptionRepository, eventRepo, application.DefaultSubscriptionBatchSize, application.DefaultEventRetryPolicy())
+	total := 0
+	for ctx.Err() == nil {
+		processed, err := processor.CatchUp(ctx, backfill)
+		total += processed
+		if err != nil {
+			log.Fatalf("Backfilled %d event(s) before failing: %v", total, err)
+		}
+		if processed == 0 {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		log.Fatalf("Interrupted after %d event(s), run backfill_outbox again to continue", total)
+	}
+	if err := outboxRepository.CompleteBackfill(ctx); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Backfilled %d event(s) into outbox", total)
+}
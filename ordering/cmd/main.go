@@ -1,11 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/application"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/infrastructure/messaging"
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/infrastructure/persistence/postgres"
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/interfaces"
@@ -25,6 +34,28 @@ var (
 	ORDER_REAND_DB    = os.Getenv("ORDER_REAND_DB")
 	APP_PORT          = os.Getenv("APP_PORT")
 	KAFKA_BROKERS     = strings.Split(os.Getenv("KAFKA_BROKERS"), ",")
+	// SYNC_HANDLER_POLICY คือ in_transaction หรือ async_fallback (ค่าเริ่มต้น)
+	// in_transaction ต้องใช้ ORDER_REAND_DB เดียวกับ ORDER_EVENT_STORE
+	SYNC_HANDLER_POLICY = os.Getenv("SYNC_HANDLER_POLICY")
+	// REQUEST_TIMEOUT คือ deadline ของแต่ละ HTTP request เช่น 10s (ค่าเริ่มต้น 30s)
+	REQUEST_TIMEOUT = os.Getenv("REQUEST_TIMEOUT")
+	// SHUTDOWN_TIMEOUT คือเวลาสูงสุดที่รอให้ HTTP request และ subscription batch ทำงานเสร็จเมื่อปิด service (ค่าเริ่มต้น 10s)
+	SHUTDOWN_TIMEOUT = os.Getenv("SHUTDOWN_TIMEOUT")
+	// SUBSCRIPTION_POLL_INTERVAL คือช่วงเวลา poll สำรองของ subscription เผื่อพลาดการแจ้งเตือนจาก LISTEN/NOTIFY (ค่าเริ่มต้น 5s)
+	SUBSCRIPTION_POLL_INTERVAL = os.Getenv("SUBSCRIPTION_POLL_INTERVAL")
+	// SUBSCRIPTION_BATCH_SIZE คือจำนวน event สูงสุดที่ subscription ประมวลผลต่อหนึ่ง transaction (ค่าเริ่มต้น 100)
+	SUBSCRIPTION_BATCH_SIZE = os.Getenv("SUBSCRIPTION_BATCH_SIZE")
+	// SUBSCRIPTION_MAX_ATTEMPTS คือจำนวนครั้งที่ทำซ้ำ handler ต่อหนึ่ง event ก่อนย้ายไป es_event_dead_letter (ค่าเริ่มต้น 5)
+	SUBSCRIPTION_MAX_ATTEMPTS = os.Getenv("SUBSCRIPTION_MAX_ATTEMPTS")
+	// OUTBOX_POLL_INTERVAL คือช่วงเวลา poll สำรองของ outbox relay เผื่อพลาดการแจ้งเตือนจาก LISTEN/NOTIFY (ค่าเริ่มต้น 5s)
+	OUTBOX_POLL_INTERVAL = os.Getenv("OUTBOX_POLL_INTERVAL")
+	// OUTBOX_RETENTION คือระยะเวลาที่เก็บ message ที่ถูกส่งแล้วไว้ใน outbox ก่อนลบ (ค่าเริ่มต้น 168h, 0 คือไม่ลบ)
+	OUTBOX_RETENTION = os.Getenv("OUTBOX_RETENTION")
+	// KAFKA_TOPIC_PARTITIONS และ KAFKA_TOPIC_REPLICATION_FACTOR ใช้เมื่อสร้าง topic ที่ยังไม่มี (ค่าเริ่มต้น 1)
+	KAFKA_TOPIC_PARTITIONS         = os.Getenv("KAFKA_TOPIC_PARTITIONS")
+	KAFKA_TOPIC_REPLICATION_FACTOR = os.Getenv("KAFKA_TOPIC_REPLICATION_FACTOR")
+	// ADMIN_TOKEN คือ bearer token ของ /admin API หากไม่กำหนดจะไม่เปิด /admin API
+	ADMIN_TOKEN = os.Getenv("ADMIN_TOKEN")
 )
 
 func ConnectPostgres(conn string) *sqlx.DB {
@@ -37,50 +68,178 @@ func ConnectPostgres(conn string) *sqlx.DB {
 
 func main() {
 	orderEventStoreDB := ConnectPostgres(ORDER_EVENT_STORE)
-	defer orderEventStoreDB.Close()
-
 	orderReadDB := ConnectPostgres(ORDER_REAND_DB)
-	defer orderReadDB.Close()
 
-	if err := runMigrations(orderEventStoreDB, "file://migrate/order_event"); err != nil {
+	syncHandlerPolicy := application.SyncHandlerAsyncFallback
+	if SYNC_HANDLER_POLICY != "" {
+		syncHandlerPolicy = application.SyncHandlerPolicy(SYNC_HANDLER_POLICY)
+	}
+	if syncHandlerPolicy != application.SyncHandlerInTransaction && syncHandlerPolicy != application.SyncHandlerAsyncFallback {
+		log.Fatalf("invalid SYNC_HANDLER_POLICY: %s", syncHandlerPolicy)
+	}
+	sharedDB := ORDER_REAND_DB == ORDER_EVENT_STORE
+	if syncHandlerPolicy == application.SyncHandlerInTransaction && !sharedDB {
+		log.Fatal("SYNC_HANDLER_POLICY=in_transaction requires ORDER_REAND_DB to be the same database as ORDER_EVENT_STORE")
+	}
+
+	if err := runMigrations(orderEventStoreDB, "file://migrate/order_event", ""); err != nil {
 		log.Fatal(err)
 	}
 
-	if err := runMigrations(orderReadDB, "file://migrate/order_read"); err != nil {
+	// ถ้าใช้ database เดียวกัน ต้องแยกตาราง migration ของ read model ออกจาก event store
+	readMigrationsTable := ""
+	if sharedDB {
+		readMigrationsTable = "schema_migrations_order_read"
+	}
+	if err := runMigrations(orderReadDB, "file://migrate/order_read", readMigrationsTable); err != nil {
 		log.Fatal(err)
 	}
 
-	eventRepo := postgres.NewEventRepository(orderEventStoreDB)
+	eventRegistry := core.NewEventRegistry()
+	order.RegisterEvents(eventRegistry)
+
+	eventStore := postgres.NewEventStore(orderEventStoreDB)
+	eventRepo := postgres.NewEventRepository(orderEventStoreDB, eventRegistry)
 	aggregateRepo := postgres.NewAggregateRepository(orderEventStoreDB)
+	aggregateLoader := core.NewAggregateLoader(eventRepo, aggregateRepo)
 	queryOrderRepository := postgres.NewQueryOrderRepository(orderReadDB)
-	subscriptionRepository := postgres.NewEventSubscriptionRepository(orderEventStoreDB)
-	messagBroker := messaging.NewKafaMessageBroker(KAFKA_BROKERS)
+	subscriptionRepository := postgres.NewEventSubscriptionRepository(orderEventStoreDB, eventRegistry)
+	outboxRepository := postgres.NewOutboxRepository(orderEventStoreDB)
+	topicConfig := messaging.DefaultTopicConfig()
+	topicConfig.NumPartitions = int32(intFromEnv("KAFKA_TOPIC_PARTITIONS", KAFKA_TOPIC_PARTITIONS, int(topicConfig.NumPartitions)))
+	topicConfig.ReplicationFactor = int16(intFromEnv("KAFKA_TOPIC_REPLICATION_FACTOR", KAFKA_TOPIC_REPLICATION_FACTOR, int(topicConfig.ReplicationFactor)))
+	messagBroker := messaging.NewKafaMessageBroker(KAFKA_BROKERS, topicConfig)
+	eventNotifier := postgres.NewEventNotifier(ORDER_EVENT_STORE)
 
 	orderProjection := application.NewOrderProjection(queryOrderRepository)
-	commandOrderUsecase := application.NewCommandOrderUsecase(eventRepo, aggregateRepo, orderProjection)
+	outbox := application.NewOutbox(outboxRepository, application.NewOrderIntegrationEventMapper())
+	unitOfWorkFactory := application.NewUnitOfWorkFactory(eventStore, outbox, syncHandlerPolicy, orderProjection)
+	commandOrderUsecase := application.NewCommandOrderUsecase(aggregateLoader, unitOfWorkFactory, application.DefaultRetryPolicy())
 	queryOrderUsecase := application.NewQueryOrderUsecase(queryOrderRepository)
-	eventSubScriptionProcessor := application.NewEventSubscriptionProcessor(subscriptionRepository, eventRepo)
-	orderIntegrationEventSender := application.NewOrderIntegrationEventSender(eventRepo, aggregateRepo, messagBroker)
+	eventRetryPolicy := application.DefaultEventRetryPolicy()
+	eventRetryPolicy.MaxAttempts = intFromEnv("SUBSCRIPTION_MAX_ATTEMPTS", SUBSCRIPTION_MAX_ATTEMPTS, eventRetryPolicy.MaxAttempts)
+	eventSubScriptionProcessor := application.NewEventSubscriptionProcessor(subscriptionRepository, eventRepo, intFromEnv("SUBSCRIPTION_BATCH_SIZE", SUBSCRIPTION_BATCH_SIZE, application.DefaultSubscriptionBatchSize), eventRetryPolicy)
+
+	orderProjectionSubscription := application.NewSyncEventHandlerSubscription(orderProjection, func() core.Aggregate { return &order.OrderAggregate{} }, aggregateLoader)
+
+	// Capture interrupt signals to gracefully shut down the service
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	go eventSubScriptionProcessor.ProcessNewEvents(orderIntegrationEventSender)
+	subscriptionSupervisor := application.NewSubscriptionSupervisor(eventSubScriptionProcessor, eventNotifier, durationFromEnv("SUBSCRIPTION_POLL_INTERVAL", SUBSCRIPTION_POLL_INTERVAL, 5*time.Second))
+	// ลงทะเบียนทั้งสอง policy เพื่อให้ /admin API ดูแล projection ได้เสมอ
+	// ใน in_transaction projection ถูกอัปเดตพร้อม event แล้ว subscription จึงข้าม event ที่ projection ถึง version นั้นแล้ว
+	subscriptionSupervisor.Register(orderProjectionSubscription)
+
+	subscriptionsDone := make(chan struct{})
+	go func() {
+		subscriptionSupervisor.Run(ctx)
+		close(subscriptionsDone)
+	}()
+
+	outboxRelay := application.NewOutboxRelay(outboxRepository, messagBroker, eventNotifier, durationFromEnv("OUTBOX_POLL_INTERVAL", OUTBOX_POLL_INTERVAL, 5*time.Second), durationFromEnv("OUTBOX_RETENTION", OUTBOX_RETENTION, 7*24*time.Hour), application.DefaultOutboxBatchSize, (&order.OrderAggregate{}).GetAggregateType())
+	outboxRelayDone := make(chan struct{})
+	go func() {
+		outboxRelay.Run(ctx)
+		close(outboxRelayDone)
+	}()
 
 	commandOrderHandler := api.NewCommandHandler(commandOrderUsecase)
 	queryOrderHandler := api.NewQueryHandler(queryOrderUsecase)
+	subscriptionAdminUsecase := application.NewSubscriptionAdminUsecase(subscriptionSupervisor, subscriptionRepository)
+	subscriptionHandler := api.NewSubscriptionHandler(subscriptionAdminUsecase)
 
 	e := echo.New()
 	e.Use(middleware.Recover())
 	e.Use(middleware.Logger())
+	e.Use(middleware.ContextTimeout(durationFromEnv("REQUEST_TIMEOUT", REQUEST_TIMEOUT, 30*time.Second)))
 
 	route := interfaces.NewRoute(e)
+	route.RegisterHTTPErrorHandler()
 	route.RegisterCommandOrderHandler(commandOrderHandler)
 	route.RegisterQueryOrderHandler(queryOrderHandler)
+	if ADMIN_TOKEN != "" {
+		route.RegisterSubscriptionHandler(subscriptionHandler, ADMIN_TOKEN)
+	} else {
+		log.Println("ADMIN_TOKEN is not set, admin API is disabled")
+	}
+
+	go func() {
+		if err := e.Start(":" + APP_PORT); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("HTTP server stopped: %v", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Received shutdown signal, shutting down ordering service...")
 
-	e.Logger.Fatal(e.Start(":" + APP_PORT))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), durationFromEnv("SHUTDOWN_TIMEOUT", SHUTDOWN_TIMEOUT, 10*time.Second))
+	defer cancel()
+
+	// หยุดรับ request ใหม่และรอ request ที่กำลังทำงานอยู่
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Error shutting down HTTP server: %v", err)
+	}
+
+	// subscription processor หยุดหลังจาก batch ปัจจุบัน commit แล้ว
+	select {
+	case <-subscriptionsDone:
+	case <-shutdownCtx.Done():
+		log.Println("Timed out waiting for subscription processors to stop")
+	}
+
+	// outbox relay หยุดหลังจาก batch ปัจจุบัน commit แล้ว ก่อนปิด message broker
+	select {
+	case <-outboxRelayDone:
+	case <-shutdownCtx.Done():
+		log.Println("Timed out waiting for outbox relay to stop")
+	}
+
+	if err := eventNotifier.Close(); err != nil {
+		log.Printf("Error closing event notifier: %v", err)
+	}
+	if err := messagBroker.Close(); err != nil {
+		log.Printf("Error closing message broker: %v", err)
+	}
+	if err := orderReadDB.Close(); err != nil {
+		log.Printf("Error closing read database: %v", err)
+	}
+	if err := orderEventStoreDB.Close(); err != nil {
+		log.Printf("Error closing event store database: %v", err)
+	}
+	log.Println("Ordering service stopped")
+}
+
+// durationFromEnv แปลงค่า environment variable เป็น time.Duration หรือคืนค่าเริ่มต้นหากไม่ได้กำหนด
+func durationFromEnv(name string, value string, defaultValue time.Duration) time.Duration {
+	if value == "" {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+	return duration
+}
+
+// intFromEnv แปลงค่า environment variable เป็น int หรือคืนค่าเริ่มต้นหากไม่ได้กำหนด
+func intFromEnv(name string, value string, defaultValue int) int {
+	if value == "" {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+	return n
 }
 
-func runMigrations(db *sqlx.DB, path string) error {
+func runMigrations(db *sqlx.DB, path string, migrationsTable string) error {
 	// Initialize migrate with a PostgreSQL database instance
-	driver, err := migrate_postgres.WithInstance(db.DB, &migrate_postgres.Config{})
+	driver, err := migrate_postgres.WithInstance(db.DB, &migrate_postgres.Config{
+		MigrationsTable: migrationsTable,
+	})
 	if err != nil {
 		return err
 	}
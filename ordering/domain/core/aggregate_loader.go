@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gofrs/uuid"
+)
+
+var ErrAggregateNotFound = errors.New("aggregate not found")
+
+// AggregateNotFoundError คืนเมื่อไม่พบทั้ง snapshot และ event ของ aggregate
+type AggregateNotFoundError struct {
+	AggregateID uuid.UUID
+}
+
+func (e *AggregateNotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrAggregateNotFound, e.AggregateID)
+}
+
+func (e *AggregateNotFoundError) Is(target error) bool {
+	return target == ErrAggregateNotFound
+}
+
+// AggregateLoader สร้าง aggregate ขึ้นมาใหม่จาก snapshot ล่าสุดและ event ที่ตามมาหลังจากนั้น
+type AggregateLoader interface {
+	LoadInto(ctx context.Context, aggregate Aggregate, id uuid.UUID, upToVersion *int) error
+}
+
+type aggregateLoader struct {
+	eventRepo     EventRepository
+	aggregateRepo AggregateRepository
+}
+
+func NewAggregateLoader(eventRepo EventRepository, aggregateRepo AggregateRepository) AggregateLoader {
+	return &aggregateLoader{
+		eventRepo:     eventRepo,
+		aggregateRepo: aggregateRepo,
+	}
+}
+
+// LoadInto implements AggregateLoader.
+func (l *aggregateLoader) LoadInto(ctx context.Context, aggregate Aggregate, id uuid.UUID, upToVersion *int) error {
+	snapshot, err := l.aggregateRepo.LoadSnapshot(ctx, id, upToVersion)
+	if err != nil {
+		return err
+	}
+	if snapshot != nil {
+		if err := snapshot.UnSerialize(aggregate); err != nil {
+			return fmt.Errorf("failed to unserialize snapshot of aggregate %s: %w", id, err)
+		}
+	}
+
+	fromVersion := aggregate.GetVersion() + 1
+	loadedEvents, err := l.eventRepo.LoadEvents(ctx, id, &fromVersion, upToVersion)
+	if err != nil {
+		return err
+	}
+
+	if snapshot == nil && len(loadedEvents) == 0 {
+		return &AggregateNotFoundError{AggregateID: id}
+	}
+
+	for _, event := range loadedEvents {
+		aggregate.Apply(event)
+	}
+	return nil
+}
+
+// LoadAggregate สร้าง aggregate ชนิด T ใหม่แล้วโหลดสถานะจาก loader
+// เช่น core.LoadAggregate[order.OrderAggregate](ctx, loader, id, nil) จะคืน *order.OrderAggregate
+func LoadAggregate[T any, PT interface {
+	*T
+	Aggregate
+}](ctx context.Context, loader AggregateLoader, id uuid.UUID, upToVersion *int) (PT, error) {
+	aggregate := PT(new(T))
+	if err := loader.LoadInto(ctx, aggregate, id, upToVersion); err != nil {
+		return nil, err
+	}
+	return aggregate, nil
+}
@@ -1,8 +1,10 @@
 package core
 
+import "context"
+
 // AsyncEventHandler คือ interface สำหรับจัดการกับเหตุการณ์ต่างๆ ในระบบ
 type AsyncEventHandler interface {
-	HandleEvent(event Event) error
+	HandleEvent(ctx context.Context, event Event) error
 	GetAggregateType() string
 	GetSubscriptionName() string
 }
@@ -0,0 +1,9 @@
+package core
+
+// EventNotifier แจ้งเตือนเมื่อมี event ใหม่ของ aggregate type ถูก commit ลง event store
+// channel ที่คืนจาก Subscribe จะได้รับสัญญาณอย่างน้อยหนึ่งครั้งหลังจากมี event ใหม่
+// ผู้ใช้ยังต้อง poll เป็นระยะเผื่อกรณีที่การแจ้งเตือนสูญหาย
+type EventNotifier interface {
+	Subscribe(aggregateType string) (<-chan struct{}, error)
+	Close() error
+}
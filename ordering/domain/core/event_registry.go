@@ -0,0 +1,63 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var ErrEventTypeNotRegistered = errors.New("event type is not registered")
+
+// EventDecoder แปลง event data ที่ถูก serialize แล้วกลับเป็น payload ของ event
+type EventDecoder func(data []byte) (interface{}, error)
+
+// EventRegistry เก็บ decoder ของ event แต่ละประเภท เพื่อให้ event store
+// deserialize event ได้โดยไม่ต้องรู้จัก domain ของแต่ละ bounded context
+type EventRegistry interface {
+	Register(eventType string, decoder EventDecoder)
+	Decode(eventType string, data []byte) (interface{}, error)
+}
+
+type eventRegistry struct {
+	mu       sync.RWMutex
+	decoders map[string]EventDecoder
+}
+
+func NewEventRegistry() EventRegistry {
+	return &eventRegistry{
+		decoders: make(map[string]EventDecoder),
+	}
+}
+
+// Register implements EventRegistry.
+func (r *eventRegistry) Register(eventType string, decoder EventDecoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.decoders[eventType]; ok {
+		panic(fmt.Sprintf("event type %s is already registered", eventType))
+	}
+	r.decoders[eventType] = decoder
+}
+
+// Decode implements EventRegistry.
+func (r *eventRegistry) Decode(eventType string, data []byte) (interface{}, error) {
+	r.mu.RLock()
+	decoder, ok := r.decoders[eventType]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrEventTypeNotRegistered, eventType)
+	}
+	return decoder(data)
+}
+
+// JSONEventDecoder สร้าง EventDecoder ที่ unmarshal JSON เป็น payload ชนิด T
+func JSONEventDecoder[T any]() EventDecoder {
+	return func(data []byte) (interface{}, error) {
+		var eventData T
+		if err := json.Unmarshal(data, &eventData); err != nil {
+			return nil, err
+		}
+		return eventData, nil
+	}
+}
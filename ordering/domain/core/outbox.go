@@ -0,0 +1,52 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+// OutboxMessage คือ integration message ที่ถูกบันทึกใน transaction เดียวกับ event และรอส่งไปยัง message broker
+type OutboxMessage struct {
+	ID            uuid.UUID
+	AggregateType string
+	AggregateID   uuid.UUID
+	Topic         string
+	Key           string
+	EventType     string
+	Payload       []byte
+	Headers       map[string]string
+	CreatedAt     time.Time
+}
+
+// NewOutboxMessage สร้าง OutboxMessage ของ event
+// ID ถูกสร้างจาก aggregate, topic และ version ของ event จึงคงที่แม้ message ถูกส่งซ้ำ
+func NewOutboxMessage(aggregate Aggregate, event Event, topic string, key string, payload []byte) OutboxMessage {
+	return OutboxMessage{
+		ID:            uuid.NewV5(event.AggregateID, fmt.Sprintf("%s/%d", topic, event.Version)),
+		AggregateType: aggregate.GetAggregateType(),
+		AggregateID:   event.AggregateID,
+		Topic:         topic,
+		Key:           key,
+		EventType:     event.EventType,
+		Payload:       payload,
+		Headers:       map[string]string{},
+		CreatedAt:     event.CreatedAt,
+	}
+}
+
+// OutboxRepository บันทึก OutboxMessage ร่วมกับ transaction ของ event store และอ่าน message ที่ยังไม่ถูกส่ง
+// LockUnpublished อ่าน message ที่ยังไม่ถูกส่งไม่เกิน limit รายการเมื่อได้ lock ของ outbox และคืนรายการว่างหาก relay อื่นถือ lock อยู่
+// DeletePublishedBefore ลบ message ที่ถูกส่งก่อน before และคืนจำนวน message ที่ถูกลบ
+// GetBackfillCutover คืน ID ของ event ล่าสุดที่ถูกบันทึกก่อนมี outbox และ backfill เสร็จไปแล้วหรือไม่
+type OutboxRepository interface {
+	SaveTx(ctx context.Context, tx *sqlx.Tx, messages []OutboxMessage) error
+	LockUnpublished(ctx context.Context, limit int) (*sqlx.Tx, []OutboxMessage, error)
+	MarkPublished(ctx context.Context, tx *sqlx.Tx, ids []uuid.UUID) error
+	DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error)
+	GetBackfillCutover(ctx context.Context) (int64, bool, error)
+	CompleteBackfill(ctx context.Context) error
+}
@@ -1,24 +1,45 @@
 package core
 
 import (
+	"context"
+	"errors"
+	"time"
+
 	"github.com/gofrs/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
+// ErrAggregateOutdated คือ error เมื่อ version ของ aggregate ใน event store ไม่ตรงกับ expectedVersion
+var ErrAggregateOutdated = errors.New("aggregate is outdated")
+
+// EventStore บันทึก version ของ aggregate และ event ใหม่ (รวมถึง snapshot) ภายใน transaction เดียวกัน
+// AppendTx ทำงานแบบเดียวกับ Append แต่ใช้ transaction ที่เปิดจาก Begin เพื่อให้งานอื่นร่วม commit ได้
+// เมื่อบันทึกสำเร็จ ID ของ event ใน events จะถูกกำหนดตามที่บันทึกใน event store
+type EventStore interface {
+	Begin(ctx context.Context) (*sqlx.Tx, error)
+	Append(ctx context.Context, aggregate Aggregate, expectedVersion int, events []Event) error
+	AppendTx(ctx context.Context, tx *sqlx.Tx, aggregate Aggregate, expectedVersion int, events []Event) error
+}
+
 type EventRepository interface {
-	SaveEvents(events []Event) error
-	LoadEvents(aggregateID uuid.UUID, fromVersion *int, toVersion *int) ([]Event, error)
+	LoadEvents(ctx context.Context, aggregateID uuid.UUID, fromVersion *int, toVersion *int) ([]Event, error)
 }
 
 type AggregateRepository interface {
-	SaveAggregate(aggregate Aggregate) error
-	SaveSnapshot(snapshot *AggregateSnapshot) error
-	LoadSnapshot(aggregateID uuid.UUID, version *int) (*AggregateSnapshot, error)
+	LoadSnapshot(ctx context.Context, aggregateID uuid.UUID, version *int) (*AggregateSnapshot, error)
 }
 
 type EventSubscriptionRepository interface {
-	CreateSubscription(subscriptionName string) error
-	ReadCheckpointAndLockSubscription(subscriptionName string) (*sqlx.Tx, *EventSubscriptionCheckpoint, error)
-	ReadEventsAfterCheckpoint(tx *sqlx.Tx, aggregateType string, lastTransactionID int64, lastEventID int64) ([]Event, error)
-	UpdateEventSubscription(tx *sqlx.Tx, subscriptionName string, lastTransactionID int64, lastEventID int64) (bool, error)
+	CreateSubscription(ctx context.Context, subscriptionName string) error
+	ReadCheckpointAndLockSubscription(ctx context.Context, subscriptionName string) (*sqlx.Tx, *EventSubscriptionCheckpoint, error)
+	ReadEventsAfterCheckpoint(ctx context.Context, tx *sqlx.Tx, aggregateType string, lastTransactionID int64, lastEventID int64, limit int) ([]Event, error)
+	UpdateEventSubscription(ctx context.Context, tx *sqlx.Tx, subscriptionName string, lastTransactionID int64, lastEventID int64) (bool, error)
+	SaveDeadLetter(ctx context.Context, tx *sqlx.Tx, subscriptionName string, event Event, attempts int, reason string) error
+	ListDeadLetters(ctx context.Context, subscriptionName string) ([]EventDeadLetter, error)
+	ReadDeadLetterEvents(ctx context.Context, tx *sqlx.Tx, subscriptionName string, limit int) ([]Event, error)
+	DeleteDeadLetter(ctx context.Context, tx *sqlx.Tx, subscriptionName string, eventID int64) error
+	GetSubscriptionInfo(ctx context.Context, subscriptionName string, aggregateType string) (*EventSubscriptionInfo, error)
+	SetSubscriptionPaused(ctx context.Context, subscriptionName string, paused bool) error
+	ResetSubscriptionToEvent(ctx context.Context, subscriptionName string, aggregateType string, eventID int64) error
+	ResetSubscriptionToTime(ctx context.Context, subscriptionName string, aggregateType string, at time.Time) error
 }
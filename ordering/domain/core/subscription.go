@@ -1,6 +1,44 @@
 package core
 
+import (
+	"errors"
+	"time"
+
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrEventNotFound        = errors.New("event not found")
+	// ErrSubscriptionLocked คือ error เมื่อ subscription ถูกล็อกโดย process อื่นหรือถูก pause อยู่
+	ErrSubscriptionLocked = errors.New("subscription is locked or paused")
+	// ErrEventAggregateTypeMismatch คือ error เมื่อ event ไม่ได้เป็นของ aggregate type ที่ subscription ประมวลผล
+	ErrEventAggregateTypeMismatch = errors.New("event does not belong to subscription aggregate type")
+)
+
 type EventSubscriptionCheckpoint struct {
 	LasttransactionID int64
 	LastEventID       int64
 }
+
+// EventSubscriptionInfo คือตำแหน่งของ subscription ใน event stream และจำนวน event ที่ยังค้างอยู่
+// LagSeconds คืออายุของ event ที่เก่าที่สุดที่ยังไม่ถูกประมวลผล
+type EventSubscriptionInfo struct {
+	SubscriptionName string
+	EventSubscriptionCheckpoint
+	Paused     bool
+	LagEvents  int64
+	LagSeconds float64
+}
+
+// EventDeadLetter คือ event ที่ subscription ประมวลผลไม่สำเร็จจนหมดจำนวนครั้งที่ทำซ้ำได้
+type EventDeadLetter struct {
+	SubscriptionName string    `json:"subscription_name"`
+	EventID          int64     `json:"event_id"`
+	AggregateID      uuid.UUID `json:"aggregate_id"`
+	Version          int       `json:"version"`
+	EventType        string    `json:"event_type"`
+	Attempts         int       `json:"attempts"`
+	Error            string    `json:"error"`
+	CreatedAt        time.Time `json:"created_at"`
+}
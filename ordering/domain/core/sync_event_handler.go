@@ -1,6 +1,27 @@
 package core
 
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
 type SyncEventHandler interface {
-	HandleEvent(aggregaate Aggregate) error
+	HandleEvent(ctx context.Context, aggregaate Aggregate) error
 	GetAggregateType() string
 }
+
+// TransactionalSyncEventHandler คือ SyncEventHandler ที่สามารถทำงานภายใน transaction เดียวกับ event store ได้
+// ใช้ได้เมื่อ read model อยู่ใน database เดียวกับ event store
+type TransactionalSyncEventHandler interface {
+	SyncEventHandler
+	HandleEventTx(ctx context.Context, tx *sqlx.Tx, aggregate Aggregate) error
+}
+
+// VersionedSyncEventHandler คือ SyncEventHandler ที่บอกได้ว่า project aggregate ไปถึง version ใดแล้ว
+// ใช้เพื่อข้าม event ที่ถูก project ไปแล้วเมื่อทำงานผ่าน async subscription
+type VersionedSyncEventHandler interface {
+	SyncEventHandler
+	GetProjectedVersion(ctx context.Context, aggregateID uuid.UUID) (int, error)
+}
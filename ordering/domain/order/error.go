@@ -3,7 +3,14 @@ package order
 import "errors"
 
 var (
-	ErrOrderIsSubmitted       = errors.New("order is submitted")
-	ErrItemAmountLessThanZero = errors.New("item amount is less than zero")
-	ErrItemNotFound           = errors.New("item not found")
+	ErrOrderNotFound             = errors.New("order not found")
+	ErrOrderIsSubmitted          = errors.New("order is submitted")
+	ErrOrderIsCancelled          = errors.New("order is cancelled")
+	ErrOrderIsCompleted          = errors.New("order is completed")
+	ErrInvalidOrderStatus        = errors.New("invalid order status")
+	ErrItemAmountLessThanZero    = errors.New("item amount is less than zero")
+	ErrItemNotFound              = errors.New("item not found")
+	ErrOrderHasNoItems           = errors.New("order has no items")
+	ErrItemAmountNotPositive     = errors.New("item amount must be greater than zero")
+	ErrCancellationReasonIsEmpty = errors.New("cancellation reason is empty")
 )
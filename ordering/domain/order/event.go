@@ -3,6 +3,7 @@ package order
 import (
 	"reflect"
 
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
 	"github.com/gofrs/uuid"
 )
 
@@ -32,3 +33,26 @@ type OrderItemAmountUpdatedEvent struct {
 func (u OrderItemAmountUpdatedEvent) GetEventType() string {
 	return reflect.TypeOf(u).Name()
 }
+
+type OrderSubmittedEvent struct{}
+
+func (s OrderSubmittedEvent) GetEventType() string {
+	return reflect.TypeOf(s).Name()
+}
+
+type OrderCancelledEvent struct {
+	Reason string `json:"reason"`
+}
+
+func (c OrderCancelledEvent) GetEventType() string {
+	return reflect.TypeOf(c).Name()
+}
+
+// RegisterEvents ลงทะเบียน event ทั้งหมดของ order ให้กับ event registry
+func RegisterEvents(registry core.EventRegistry) {
+	registry.Register(OrderCreatedEvent{}.GetEventType(), core.JSONEventDecoder[OrderCreatedEvent]())
+	registry.Register(OrderUpdatedEvent{}.GetEventType(), core.JSONEventDecoder[OrderUpdatedEvent]())
+	registry.Register(OrderItemAmountUpdatedEvent{}.GetEventType(), core.JSONEventDecoder[OrderItemAmountUpdatedEvent]())
+	registry.Register(OrderSubmittedEvent{}.GetEventType(), core.JSONEventDecoder[OrderSubmittedEvent]())
+	registry.Register(OrderCancelledEvent{}.GetEventType(), core.JSONEventDecoder[OrderCancelledEvent]())
+}
@@ -8,12 +8,13 @@ import (
 )
 
 type OrderAggregate struct {
-	ID          uuid.UUID    `json:"id"`
-	Name        string       `json:"name"`
-	OrderItems  []OrderItem  `json:"order_items"`
-	IsSubmitted bool         `json:"is_submitted"`
-	Version     int          `json:"version"`
-	Events      []core.Event `json:"-"`
+	ID                 uuid.UUID    `json:"id"`
+	Name               string       `json:"name"`
+	OrderItems         []OrderItem  `json:"order_items"`
+	Status             OrderStatus  `json:"status"`
+	CancellationReason string       `json:"cancellation_reason,omitempty"`
+	Version            int          `json:"version"`
+	Events             []core.Event `json:"-"`
 }
 
 type OrderItem struct {
@@ -41,6 +42,7 @@ func (o *OrderAggregate) Apply(event core.Event) {
 		o.ID = event.AggregateID
 		o.Name = createdEvent.Name
 		o.OrderItems = createdEvent.OrderItems
+		o.Status = OrderStatusDraft
 	case reflect.TypeOf(OrderUpdatedEvent{}).Name():
 		updatedEvent := event.EventData.(OrderUpdatedEvent)
 		o.Name = updatedEvent.Name
@@ -53,6 +55,12 @@ func (o *OrderAggregate) Apply(event core.Event) {
 				break
 			}
 		}
+	case reflect.TypeOf(OrderSubmittedEvent{}).Name():
+		o.Status = OrderStatusSubmitted
+	case reflect.TypeOf(OrderCancelledEvent{}).Name():
+		cancelledEvent := event.EventData.(OrderCancelledEvent)
+		o.Status = OrderStatusCancelled
+		o.CancellationReason = cancelledEvent.Reason
 	}
 	o.Version++
 }
@@ -82,8 +90,8 @@ func CreateOrderWithItems(name string, orderItems []OrderItem) *OrderAggregate {
 }
 
 func (o *OrderAggregate) UpdatedOrderWithItems(name string, orderItems []OrderItem) error {
-	if o.IsSubmitted {
-		return ErrOrderIsSubmitted
+	if o.Status != OrderStatusDraft {
+		return o.Status.transitionError()
 	}
 	eventData := OrderUpdatedEvent{
 		Name:       name,
@@ -97,8 +105,8 @@ func (o *OrderAggregate) UpdatedOrderWithItems(name string, orderItems []OrderIt
 }
 
 func (o *OrderAggregate) UpdateOrderItemAmount(id uuid.UUID, amount int) error {
-	if o.IsSubmitted {
-		return ErrOrderIsSubmitted
+	if o.Status != OrderStatusDraft {
+		return o.Status.transitionError()
 	}
 
 	if amount < 0 {
@@ -126,3 +134,43 @@ func (o *OrderAggregate) UpdateOrderItemAmount(id uuid.UUID, amount int) error {
 	o.Apply(updatedOrderEvent)
 	return nil
 }
+
+func (o *OrderAggregate) Submit() error {
+	if !o.Status.CanTransitionTo(OrderStatusSubmitted) {
+		return o.Status.transitionError()
+	}
+
+	if len(o.OrderItems) == 0 {
+		return ErrOrderHasNoItems
+	}
+
+	for _, item := range o.OrderItems {
+		if item.Amount <= 0 {
+			return ErrItemAmountNotPositive
+		}
+	}
+
+	eventData := OrderSubmittedEvent{}
+	submittedOrderEvent := core.NewEvent(o.GetID(), eventData.GetEventType(), eventData)
+	o.appendEvent(submittedOrderEvent)
+	o.Apply(submittedOrderEvent)
+	return nil
+}
+
+func (o *OrderAggregate) Cancel(reason string) error {
+	if !o.Status.CanTransitionTo(OrderStatusCancelled) {
+		return o.Status.transitionError()
+	}
+
+	if reason == "" {
+		return ErrCancellationReasonIsEmpty
+	}
+
+	eventData := OrderCancelledEvent{
+		Reason: reason,
+	}
+	cancelledOrderEvent := core.NewEvent(o.GetID(), eventData.GetEventType(), eventData)
+	o.appendEvent(cancelledOrderEvent)
+	o.Apply(cancelledOrderEvent)
+	return nil
+}
@@ -0,0 +1,255 @@
+package order
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gofrs/uuid"
+)
+
+func TestOrderStatusCanTransitionTo(t *testing.T) {
+	tests := []struct {
+		name string
+		from OrderStatus
+		to   OrderStatus
+		want bool
+	}{
+		{name: "draft can be submitted", from: OrderStatusDraft, to: OrderStatusSubmitted, want: true},
+		{name: "draft can be cancelled", from: OrderStatusDraft, to: OrderStatusCancelled, want: true},
+		{name: "draft cannot be completed", from: OrderStatusDraft, to: OrderStatusCompleted, want: false},
+		{name: "submitted can be cancelled", from: OrderStatusSubmitted, to: OrderStatusCancelled, want: true},
+		{name: "submitted can be completed", from: OrderStatusSubmitted, to: OrderStatusCompleted, want: true},
+		{name: "submitted cannot be submitted again", from: OrderStatusSubmitted, to: OrderStatusSubmitted, want: false},
+		{name: "cancelled is final", from: OrderStatusCancelled, to: OrderStatusSubmitted, want: false},
+		{name: "completed is final", from: OrderStatusCompleted, to: OrderStatusCancelled, want: false},
+		{name: "unknown status cannot change", from: OrderStatus("UNKNOWN"), to: OrderStatusSubmitted, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Errorf("%s.CanTransitionTo(%s) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderAggregateSubmit(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     OrderStatus
+		items      []OrderItem
+		wantErr    error
+		wantStatus OrderStatus
+	}{
+		{
+			name:       "draft order with items is submitted",
+			status:     OrderStatusDraft,
+			items:      []OrderItem{{Name: "A", Amount: 1}},
+			wantStatus: OrderStatusSubmitted,
+		},
+		{
+			name:       "draft order without items is rejected",
+			status:     OrderStatusDraft,
+			wantErr:    ErrOrderHasNoItems,
+			wantStatus: OrderStatusDraft,
+		},
+		{
+			name:       "draft order with zero amount is rejected",
+			status:     OrderStatusDraft,
+			items:      []OrderItem{{Name: "A", Amount: 1}, {Name: "B", Amount: 0}},
+			wantErr:    ErrItemAmountNotPositive,
+			wantStatus: OrderStatusDraft,
+		},
+		{
+			name:       "submitted order is rejected",
+			status:     OrderStatusSubmitted,
+			items:      []OrderItem{{Name: "A", Amount: 1}},
+			wantErr:    ErrOrderIsSubmitted,
+			wantStatus: OrderStatusSubmitted,
+		},
+		{
+			name:       "cancelled order is rejected",
+			status:     OrderStatusCancelled,
+			items:      []OrderItem{{Name: "A", Amount: 1}},
+			wantErr:    ErrOrderIsCancelled,
+			wantStatus: OrderStatusCancelled,
+		},
+		{
+			name:       "completed order is rejected",
+			status:     OrderStatusCompleted,
+			items:      []OrderItem{{Name: "A", Amount: 1}},
+			wantErr:    ErrOrderIsCompleted,
+			wantStatus: OrderStatusCompleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := newTestOrder(tt.status, tt.items)
+			err := order.Submit()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Submit() error = %v, want %v", err, tt.wantErr)
+			}
+			assertOrderChange(t, order, tt.wantErr, tt.wantStatus)
+		})
+	}
+}
+
+func TestOrderAggregateCancel(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     OrderStatus
+		reason     string
+		wantErr    error
+		wantStatus OrderStatus
+	}{
+		{
+			name:       "draft order is cancelled",
+			status:     OrderStatusDraft,
+			reason:     "changed my mind",
+			wantStatus: OrderStatusCancelled,
+		},
+		{
+			name:       "submitted order is cancelled",
+			status:     OrderStatusSubmitted,
+			reason:     "out of budget",
+			wantStatus: OrderStatusCancelled,
+		},
+		{
+			name:       "empty reason is rejected",
+			status:     OrderStatusDraft,
+			wantErr:    ErrCancellationReasonIsEmpty,
+			wantStatus: OrderStatusDraft,
+		},
+		{
+			name:       "cancelled order is rejected",
+			status:     OrderStatusCancelled,
+			reason:     "again",
+			wantErr:    ErrOrderIsCancelled,
+			wantStatus: OrderStatusCancelled,
+		},
+		{
+			name:       "completed order is rejected",
+			status:     OrderStatusCompleted,
+			reason:     "too late",
+			wantErr:    ErrOrderIsCompleted,
+			wantStatus: OrderStatusCompleted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := newTestOrder(tt.status, []OrderItem{{Name: "A", Amount: 1}})
+			err := order.Cancel(tt.reason)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Cancel() error = %v, want %v", err, tt.wantErr)
+			}
+			assertOrderChange(t, order, tt.wantErr, tt.wantStatus)
+			if tt.wantErr == nil && order.CancellationReason != tt.reason {
+				t.Errorf("cancellation reason = %q, want %q", order.CancellationReason, tt.reason)
+			}
+		})
+	}
+}
+
+func TestOrderAggregateChangesOnlyInDraft(t *testing.T) {
+	itemID, _ := uuid.NewV4()
+	tests := []struct {
+		name    string
+		status  OrderStatus
+		change  func(order *OrderAggregate) error
+		wantErr error
+	}{
+		{
+			name:   "draft order is updated",
+			status: OrderStatusDraft,
+			change: func(order *OrderAggregate) error {
+				return order.UpdatedOrderWithItems("renamed", []OrderItem{{ID: itemID, Name: "A", Amount: 2}})
+			},
+		},
+		{
+			name:   "submitted order cannot be updated",
+			status: OrderStatusSubmitted,
+			change: func(order *OrderAggregate) error {
+				return order.UpdatedOrderWithItems("renamed", nil)
+			},
+			wantErr: ErrOrderIsSubmitted,
+		},
+		{
+			name:   "draft item amount is updated",
+			status: OrderStatusDraft,
+			change: func(order *OrderAggregate) error {
+				return order.UpdateOrderItemAmount(itemID, 3)
+			},
+		},
+		{
+			name:   "negative item amount is rejected",
+			status: OrderStatusDraft,
+			change: func(order *OrderAggregate) error {
+				return order.UpdateOrderItemAmount(itemID, -1)
+			},
+			wantErr: ErrItemAmountLessThanZero,
+		},
+		{
+			name:   "unknown item is rejected",
+			status: OrderStatusDraft,
+			change: func(order *OrderAggregate) error {
+				return order.UpdateOrderItemAmount(uuid.Nil, 1)
+			},
+			wantErr: ErrItemNotFound,
+		},
+		{
+			name:   "cancelled item amount cannot be updated",
+			status: OrderStatusCancelled,
+			change: func(order *OrderAggregate) error {
+				return order.UpdateOrderItemAmount(itemID, 1)
+			},
+			wantErr: ErrOrderIsCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := newTestOrder(tt.status, []OrderItem{{ID: itemID, Name: "A", Amount: 1}})
+			err := tt.change(order)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("change error = %v, want %v", err, tt.wantErr)
+			}
+			assertOrderChange(t, order, tt.wantErr, tt.status)
+		})
+	}
+}
+
+// newTestOrder สร้าง order ที่อยู่ในสถานะ status โดยไม่มี event ที่ยังไม่ถูกบันทึก
+func newTestOrder(status OrderStatus, items []OrderItem) *OrderAggregate {
+	id, _ := uuid.NewV4()
+	return &OrderAggregate{
+		ID:         id,
+		Name:       "order",
+		OrderItems: items,
+		Status:     status,
+		Version:    1,
+	}
+}
+
+// assertOrderChange ตรวจสอบว่าคำสั่งที่สำเร็จสร้าง event หนึ่งรายการต่อจาก version เดิม
+// และคำสั่งที่ล้มเหลวไม่สร้าง event
+func assertOrderChange(t *testing.T, order *OrderAggregate, err error, wantStatus OrderStatus) {
+	t.Helper()
+	if order.Status != wantStatus {
+		t.Errorf("status = %s, want %s", order.Status, wantStatus)
+	}
+	if err != nil {
+		if len(order.Events) != 0 {
+			t.Errorf("events = %d, want 0", len(order.Events))
+		}
+		return
+	}
+	if len(order.Events) != 1 {
+		t.Fatalf("events = %d, want 1", len(order.Events))
+	}
+	if got := order.Events[0].Version; got != 2 {
+		t.Errorf("event version = %d, want 2", got)
+	}
+}
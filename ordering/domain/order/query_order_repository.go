@@ -1,15 +1,25 @@
 package order
 
-import "github.com/gofrs/uuid"
+import (
+	"context"
+
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+)
 
 type Order struct {
-	ID          uuid.UUID
-	Name        string
-	OrderItems  []OrderItem
-	IsSubmitted bool
+	ID                 uuid.UUID   `json:"id"`
+	Version            int         `json:"version"`
+	Name               string      `json:"name"`
+	OrderItems         []OrderItem `json:"order_items"`
+	Status             OrderStatus `json:"status"`
+	CancellationReason string      `json:"cancellation_reason,omitempty"`
 }
 
 type QueryOrderRepository interface {
-	GetOrders() ([]Order, error)
-	SaveOrder(OrderAggregate *OrderAggregate) error
+	GetOrders(ctx context.Context) ([]Order, error)
+	GetOrder(ctx context.Context, id uuid.UUID) (*Order, error)
+	SaveOrder(ctx context.Context, OrderAggregate *OrderAggregate) error
+	SaveOrderTx(ctx context.Context, tx *sqlx.Tx, OrderAggregate *OrderAggregate) error
+	GetOrderVersion(ctx context.Context, id uuid.UUID) (int, error)
 }
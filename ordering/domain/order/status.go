@@ -0,0 +1,42 @@
+package order
+
+type OrderStatus string
+
+const (
+	OrderStatusDraft     OrderStatus = "DRAFT"
+	OrderStatusSubmitted OrderStatus = "SUBMITTED"
+	OrderStatusCancelled OrderStatus = "CANCELLED"
+	OrderStatusCompleted OrderStatus = "COMPLETED"
+)
+
+// orderStatusTransitions กำหนดสถานะถัดไปที่อนุญาตจากแต่ละสถานะ
+var orderStatusTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusDraft:     {OrderStatusSubmitted, OrderStatusCancelled},
+	OrderStatusSubmitted: {OrderStatusCancelled, OrderStatusCompleted},
+	OrderStatusCancelled: {},
+	OrderStatusCompleted: {},
+}
+
+// CanTransitionTo ตรวจสอบว่าสามารถเปลี่ยนจากสถานะปัจจุบันไปยังสถานะ next ได้หรือไม่
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, status := range orderStatusTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// transitionError คืน error ที่อธิบายว่าทำไมคำสั่งจึงไม่สามารถทำได้ในสถานะปัจจุบัน
+func (s OrderStatus) transitionError() error {
+	switch s {
+	case OrderStatusSubmitted:
+		return ErrOrderIsSubmitted
+	case OrderStatusCancelled:
+		return ErrOrderIsCancelled
+	case OrderStatusCompleted:
+		return ErrOrderIsCompleted
+	default:
+		return ErrInvalidOrderStatus
+	}
+}
@@ -3,6 +3,7 @@ module github.com/Bass-Peerapon/eventsource-demo/ordering
 go 1.19
 
 require (
+	github.com/Bass-Peerapon/eventsource-demo/contracts v0.0.0
 	github.com/IBM/sarama v1.43.3
 	github.com/gofrs/uuid v4.4.0+incompatible
 	github.com/golang-migrate/migrate/v4 v4.17.1
@@ -43,3 +44,5 @@ require (
 	golang.org/x/text v0.18.0 // indirect
 	golang.org/x/time v0.5.0 // indirect
 )
+
+replace github.com/Bass-Peerapon/eventsource-demo/contracts => ../contracts
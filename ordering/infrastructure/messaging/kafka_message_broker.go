@@ -1,15 +1,42 @@
 package messaging
 
 import (
+	"context"
 	"fmt"
 
+	"github.com/Bass-Peerapon/eventsource-demo/contracts"
 	"github.com/IBM/sarama"
 )
 
-const TOPIC_ORDER_EVENT = "ORDER_EVENT"
+const TOPIC_ORDER_EVENT = contracts.TOPIC_ORDER_EVENT
+
+// HEADER_MESSAGE_ID คือ header ที่เก็บ ID ของ message ซึ่งคงที่เมื่อส่งซ้ำ ให้ consumer ใช้ตัด message ซ้ำ
+const HEADER_MESSAGE_ID = contracts.HEADER_MESSAGE_ID
+
+// Message คือ message ที่จะส่งไปยัง message broker
+type Message struct {
+	Topic   string
+	Key     string
+	Value   []byte
+	Headers map[string]string
+}
+
+// TopicConfig กำหนดจำนวน partition และ replication factor ของ topic ที่ถูกสร้างเมื่อยังไม่มี
+type TopicConfig struct {
+	NumPartitions     int32
+	ReplicationFactor int16
+}
+
+func DefaultTopicConfig() TopicConfig {
+	return TopicConfig{
+		NumPartitions:     1,
+		ReplicationFactor: 1,
+	}
+}
 
 type MessageBroker interface {
-	Publish(topic string, key string, value []byte) error
+	Publish(ctx context.Context, message Message) error
+	Close() error
 }
 
 type kafkaMessageBroker struct {
@@ -17,16 +44,30 @@ type kafkaMessageBroker struct {
 }
 
 // Publish implements MessageBroker.
-func (k *kafkaMessageBroker) Publish(topic string, key string, value []byte) error {
+func (k *kafkaMessageBroker) Publish(ctx context.Context, message Message) error {
+	// sarama.SyncProducer ไม่รองรับ context จึงตรวจสอบก่อนส่งเพื่อไม่ส่ง message หลังถูกยกเลิก
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	headers := make([]sarama.RecordHeader, 0, len(message.Headers))
+	for key, value := range message.Headers {
+		headers = append(headers, sarama.RecordHeader{Key: []byte(key), Value: []byte(value)})
+	}
 	_, _, err := k.producer.SendMessage(&sarama.ProducerMessage{
-		Topic: topic,
-		Key:   sarama.StringEncoder(key),
-		Value: sarama.ByteEncoder(value),
+		Topic:   message.Topic,
+		Key:     sarama.StringEncoder(message.Key),
+		Value:   sarama.ByteEncoder(message.Value),
+		Headers: headers,
 	})
 	return err
 }
 
-func NewKafaMessageBroker(brokers []string) MessageBroker {
+// Close implements MessageBroker.
+func (k *kafkaMessageBroker) Close() error {
+	return k.producer.Close()
+}
+
+func NewKafaMessageBroker(brokers []string, topicConfig TopicConfig) MessageBroker {
 	// Create admin client
 	admin, err := newKafkaAdmin(brokers)
 	if err != nil {
@@ -35,7 +76,7 @@ func NewKafaMessageBroker(brokers []string) MessageBroker {
 	defer admin.Close()
 
 	// Create topic if it does not exist
-	err = createTopicIfNotExists(admin, TOPIC_ORDER_EVENT)
+	err = createTopicIfNotExists(admin, TOPIC_ORDER_EVENT, topicConfig)
 	if err != nil {
 		panic(err)
 	}
@@ -56,6 +97,8 @@ func newKafkaProducer(brokers []string) (sarama.SyncProducer, error) {
 	config.Producer.Return.Successes = true
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Retry.Max = 5
+	// message ที่มี key เดียวกัน (aggregate ID) จะถูกส่งไปยัง partition เดียวกันเสมอ
+	config.Producer.Partitioner = sarama.NewHashPartitioner
 
 	producer, err := sarama.NewSyncProducer(brokers, config)
 	if err != nil {
@@ -77,22 +120,25 @@ func newKafkaAdmin(brokers []string) (sarama.ClusterAdmin, error) {
 	return admin, nil
 }
 
-func createTopicIfNotExists(admin sarama.ClusterAdmin, topic string) error {
+func createTopicIfNotExists(admin sarama.ClusterAdmin, topic string, topicConfig TopicConfig) error {
 	topics, err := admin.ListTopics()
 	if err != nil {
 		return fmt.Errorf("failed to list topics: %w", err)
 	}
 
 	// Check if the topic already exists
-	if _, ok := topics[topic]; ok {
-		fmt.Printf("Topic %s already exists\n", topic)
+	if detail, ok := topics[topic]; ok {
+		fmt.Printf("Topic %s already exists with %d partition(s)\n", topic, detail.NumPartitions)
+		if detail.NumPartitions < topicConfig.NumPartitions {
+			fmt.Printf("Topic %s has fewer partitions than configured (%d), existing partitions are not changed\n", topic, topicConfig.NumPartitions)
+		}
 		return nil
 	}
 
 	// Define topic details
 	topicDetail := &sarama.TopicDetail{
-		NumPartitions:     1,
-		ReplicationFactor: 1,
+		NumPartitions:     topicConfig.NumPartitions,
+		ReplicationFactor: topicConfig.ReplicationFactor,
 		ConfigEntries:     make(map[string]*string),
 	}
 
@@ -1,8 +1,8 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -16,38 +16,8 @@ type aggregateRepository struct {
 	db *sqlx.DB
 }
 
-// SaveAggregate implements core.AggregateRepository.
-func (s *aggregateRepository) SaveAggregate(aggregate core.Aggregate) error {
-	tx, err := s.db.Beginx()
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-	query := `
-        INSERT INTO es_aggregate (id, version, aggregate_type)
-        VALUES ($1, $2, $3)
-				ON CONFLICT (id) WHERE es_aggregate.version = $2
-				DO UPDATE SET
-				version = $2
-    `
-	result, err := tx.Exec(query, aggregate.GetID(), aggregate.GetVersion(), aggregate.GetAggregateType())
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return err
-	}
-	if rowsAffected == 0 {
-		return ErrAggregateOutdated
-	}
-
-	return tx.Commit()
-}
-
 // LoadSnapshot implements core.SnapshotStore.
-func (s *aggregateRepository) LoadSnapshot(aggregateID uuid.UUID, version *int) (*core.AggregateSnapshot, error) {
+func (s *aggregateRepository) LoadSnapshot(ctx context.Context, aggregateID uuid.UUID, version *int) (*core.AggregateSnapshot, error) {
 	conds := []string{}
 	args := []interface{}{}
 
@@ -84,7 +54,7 @@ func (s *aggregateRepository) LoadSnapshot(aggregateID uuid.UUID, version *int)
 		where,
 	)
 	query = sqlx.Rebind(sqlx.DOLLAR, query)
-	if err := s.db.Get(&snapshot, query, args...); err != nil {
+	if err := s.db.GetContext(ctx, &snapshot, query, args...); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
@@ -93,26 +63,6 @@ func (s *aggregateRepository) LoadSnapshot(aggregateID uuid.UUID, version *int)
 	return &snapshot, nil
 }
 
-// SaveSnapshot implements core.SnapshotStore.
-func (s *aggregateRepository) SaveSnapshot(snapshot *core.AggregateSnapshot) error {
-	tx, err := s.db.Beginx()
-	if err != nil {
-		return err
-	}
-
-	defer tx.Rollback()
-	query := `
-		INSERT INTO es_aggregate_snapshot (aggregate_id, version, event_data)
-		VALUES ($1, $2, $3)
-		`
-	eventData, _ := json.Marshal(snapshot.EventData)
-
-	if _, err := tx.Exec(query, snapshot.AggregateID, snapshot.Version, eventData); err != nil {
-		return err
-	}
-	return tx.Commit()
-}
-
 func NewAggregateRepository(db *sqlx.DB) core.AggregateRepository {
 	return &aggregateRepository{
 		db: db,
@@ -1,5 +1,35 @@
 package postgres
 
-import "errors"
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
 
-var ErrAggregateOutdated = errors.New("aggregate is outdated")
+	"github.com/lib/pq"
+)
+
+// transientErrorClasses คือ class ของ SQLSTATE ที่อาจสำเร็จเมื่อทำซ้ำ
+// 08 connection exception, 40 transaction rollback (serialization failure, deadlock),
+// 53 insufficient resources และ 57 operator intervention
+var transientErrorClasses = map[pq.ErrorClass]bool{
+	"08": true,
+	"40": true,
+	"53": true,
+	"57": true,
+}
+
+// IsTransient คืน true เมื่อ err เกิดจากการเชื่อมต่อหรือการแย่งกันใช้ database ซึ่งอาจสำเร็จเมื่อทำซ้ำ
+// error อื่น เช่น การแปลง event ไม่สำเร็จ จะล้มเหลวเหมือนเดิมทุกครั้ง
+func IsTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return transientErrorClasses[pqErr.Code.Class()] || pqErr.Code == "55P03" // lock_not_available
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,41 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/gofrs/uuid"
+)
+
+type event struct {
+	ID            int64           `json:"id" db:"id"`
+	TransactionID int64           `json:"transaction_id" db:"transaction_id"`
+	AggregateID   uuid.UUID       `json:"aggregate_id" db:"aggregate_id"`
+	EventType     string          `json:"event_type" db:"event_type"`
+	EventData     json.RawMessage `json:"event_data" db:"event_data"`
+	Version       int             `json:"version" db:"version"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// decodeEvents แปลง event ที่อ่านจาก es_event เป็น core.Event โดยใช้ decoder จาก registry
+func decodeEvents(registry core.EventRegistry, events []event) ([]core.Event, error) {
+	loadedEvents := make([]core.Event, 0, len(events))
+	for _, event := range events {
+		eventData, err := registry.Decode(event.EventType, event.EventData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode event %d: %w", event.ID, err)
+		}
+		loadedEvents = append(loadedEvents, core.Event{
+			ID:            event.ID,
+			TransactionID: event.TransactionID,
+			AggregateID:   event.AggregateID,
+			EventType:     event.EventType,
+			EventData:     eventData,
+			Version:       event.Version,
+			CreatedAt:     event.CreatedAt,
+		})
+	}
+	return loadedEvents, nil
+}
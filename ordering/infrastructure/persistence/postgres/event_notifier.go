@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/helper"
+	"github.com/lib/pq"
+)
+
+const (
+	listenerMinReconnectInterval = 1 * time.Second
+	listenerMaxReconnectInterval = 30 * time.Second
+)
+
+// EventNotificationChannel คืนชื่อ channel ของ LISTEN/NOTIFY สำหรับ aggregate type
+func EventNotificationChannel(aggregateType string) string {
+	return "es_event_" + strings.ToLower(aggregateType)
+}
+
+type eventNotifier struct {
+	listener *pq.Listener
+
+	mu          sync.Mutex
+	subscribers map[string][]chan struct{}
+	done        chan struct{}
+}
+
+// NewEventNotifier สร้าง core.EventNotifier ที่ LISTEN การแจ้งเตือนจาก event store ผ่าน lib/pq
+func NewEventNotifier(conn string) core.EventNotifier {
+	n := &eventNotifier{
+		subscribers: make(map[string][]chan struct{}),
+		done:        make(chan struct{}),
+	}
+	n.listener = pq.NewListener(conn, listenerMinReconnectInterval, listenerMaxReconnectInterval, n.handleListenerEvent)
+	go n.dispatch()
+	return n
+}
+
+// Subscribe implements core.EventNotifier.
+func (n *eventNotifier) Subscribe(aggregateType string) (<-chan struct{}, error) {
+	channel := EventNotificationChannel(aggregateType)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if _, ok := n.subscribers[channel]; !ok {
+		if err := n.listener.Listen(channel); err != nil {
+			return nil, fmt.Errorf("failed to listen on %s: %w", channel, err)
+		}
+	}
+	wakeup := make(chan struct{}, 1)
+	n.subscribers[channel] = append(n.subscribers[channel], wakeup)
+	return wakeup, nil
+}
+
+// Close implements core.EventNotifier.
+func (n *eventNotifier) Close() error {
+	close(n.done)
+	return n.listener.Close()
+}
+
+func (n *eventNotifier) dispatch() {
+	for {
+		select {
+		case <-n.done:
+			return
+		case notification, ok := <-n.listener.Notify:
+			if !ok {
+				return
+			}
+			// notification เป็น nil เมื่อเชื่อมต่อใหม่ ซึ่งอาจพลาดการแจ้งเตือนไป จึงปลุกทุก subscriber
+			if notification == nil {
+				n.wakeAll()
+				continue
+			}
+			n.wake(notification.Channel)
+		}
+	}
+}
+
+func (n *eventNotifier) wake(channel string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, wakeup := range n.subscribers[channel] {
+		signal(wakeup)
+	}
+}
+
+func (n *eventNotifier) wakeAll() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, subscribers := range n.subscribers {
+		for _, wakeup := range subscribers {
+			signal(wakeup)
+		}
+	}
+}
+
+func (n *eventNotifier) handleListenerEvent(event pq.ListenerEventType, err error) {
+	if err != nil {
+		helper.Println(fmt.Sprintf("Event notifier listener error: %v", err))
+	}
+}
+
+// signal ส่งสัญญาณแบบไม่ block หาก subscriber ยังไม่ได้รับสัญญาณก่อนหน้า ก็ไม่จำเป็นต้องส่งซ้ำ
+func signal(wakeup chan struct{}) {
+	select {
+	case wakeup <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,143 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// SNAPSHOT_FREQUENCY จำนวน version ที่จะบันทึก snapshot ของ aggregate หนึ่งครั้ง
+const SNAPSHOT_FREQUENCY = 10
+
+// pgUniqueViolation คือ SQLSTATE ของ unique_violation
+const pgUniqueViolation = "23505"
+
+type eventStore struct {
+	db *sqlx.DB
+}
+
+func NewEventStore(db *sqlx.DB) core.EventStore {
+	return &eventStore{
+		db: db,
+	}
+}
+
+// Begin implements core.EventStore.
+func (s *eventStore) Begin(ctx context.Context) (*sqlx.Tx, error) {
+	return s.db.BeginTxx(ctx, nil)
+}
+
+// Append implements core.EventStore.
+func (s *eventStore) Append(ctx context.Context, aggregate core.Aggregate, expectedVersion int, events []core.Event) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.AppendTx(ctx, tx, aggregate, expectedVersion, events); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AppendTx implements core.EventStore.
+func (s *eventStore) AppendTx(ctx context.Context, tx *sqlx.Tx, aggregate core.Aggregate, expectedVersion int, events []core.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	if err := s.saveAggregate(ctx, tx, aggregate, expectedVersion); err != nil {
+		return err
+	}
+
+	shouldSnapshot := false
+	for i, event := range events {
+		id, err := s.saveEvent(ctx, tx, event)
+		if err != nil {
+			return err
+		}
+		events[i].ID = id
+		if event.Version%SNAPSHOT_FREQUENCY == 0 {
+			shouldSnapshot = true
+		}
+	}
+
+	if shouldSnapshot {
+		if err := s.saveSnapshot(ctx, tx, aggregate); err != nil {
+			return err
+		}
+	}
+	return s.notify(ctx, tx, aggregate)
+}
+
+func (s *eventStore) saveAggregate(ctx context.Context, tx *sqlx.Tx, aggregate core.Aggregate, expectedVersion int) error {
+	query := `
+INSERT INTO es_aggregate (id, version, aggregate_type)
+    VALUES ($1, $2, $3)
+ON CONFLICT (id)
+    DO UPDATE SET
+        version = $2
+    WHERE
+        es_aggregate.version = $4
+	`
+	result, err := tx.ExecContext(ctx, query, aggregate.GetID(), aggregate.GetVersion(), aggregate.GetAggregateType(), expectedVersion)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return core.ErrAggregateOutdated
+	}
+	return nil
+}
+
+// saveEvent บันทึก event และคืน ID ที่ถูกสร้างใน es_event
+func (s *eventStore) saveEvent(ctx context.Context, tx *sqlx.Tx, event core.Event) (int64, error) {
+	query := `
+INSERT INTO es_event (transaction_id, aggregate_id, version, event_type, event_data, created_at)
+    VALUES (pg_current_xact_id(), $1, $2, $3, $4, $5)
+RETURNING
+    id
+	`
+	eventData, err := json.Marshal(event.EventData)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	if err := tx.QueryRowxContext(ctx, query, event.AggregateID, event.Version, event.EventType, eventData, event.CreatedAt).Scan(&id); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return 0, core.ErrAggregateOutdated
+		}
+		return 0, err
+	}
+	return id, nil
+}
+
+func (s *eventStore) saveSnapshot(ctx context.Context, tx *sqlx.Tx, aggregate core.Aggregate) error {
+	query := `
+INSERT INTO es_aggregate_snapshot (aggregate_id, version, event_data)
+    VALUES ($1, $2, $3)
+	`
+	eventData, err := json.Marshal(aggregate)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, query, aggregate.GetID(), aggregate.GetVersion(), eventData)
+	return err
+}
+
+// notify ส่ง pg_notify ไปยัง channel ของ aggregate type ซึ่ง postgres จะส่งจริงเมื่อ transaction commit แล้วเท่านั้น
+func (s *eventStore) notify(ctx context.Context, tx *sqlx.Tx, aggregate core.Aggregate) error {
+	_, err := tx.ExecContext(ctx, `SELECT pg_notify($1, $2)`, EventNotificationChannel(aggregate.GetAggregateType()), aggregate.GetID().String())
+	return err
+}
@@ -1,32 +1,34 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"reflect"
 	"strconv"
+	"time"
 
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
-	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
+	"github.com/gofrs/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
 type eventSubscriptionRepository struct {
-	db *sqlx.DB
+	db       *sqlx.DB
+	registry core.EventRegistry
 }
 
 // NewEventSubscriptionRepository ฟังก์ชันสำหรับสร้าง EventSubscriptionRepository ใหม่
-func NewEventSubscriptionRepository(db *sqlx.DB) core.EventSubscriptionRepository {
+func NewEventSubscriptionRepository(db *sqlx.DB, registry core.EventRegistry) core.EventSubscriptionRepository {
 	return &eventSubscriptionRepository{
-		db: db,
+		db:       db,
+		registry: registry,
 	}
 }
 
 // CreateSubscriptionIfAbsent สร้าง subscription หากยังไม่มี
-func (r *eventSubscriptionRepository) CreateSubscription(subscriptionName string) error {
-	tx, err := r.db.Beginx()
+func (r *eventSubscriptionRepository) CreateSubscription(ctx context.Context, subscriptionName string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
@@ -38,7 +40,7 @@ INSERT INTO es_event_subscription (subscription_name, last_transaction_id, last_
 ON CONFLICT
     DO NOTHING
 	`
-	_, err = tx.Exec(query, subscriptionName)
+	_, err = tx.ExecContext(ctx, query, subscriptionName)
 	if err != nil {
 		return fmt.Errorf("failed to create subscription if absent: %w", err)
 	}
@@ -46,8 +48,9 @@ ON CONFLICT
 }
 
 // ReadCheckpointAndLockSubscription อ่าน checkpoint และทำการล็อก subscription
-func (r *eventSubscriptionRepository) ReadCheckpointAndLockSubscription(subscriptionName string) (*sqlx.Tx, *core.EventSubscriptionCheckpoint, error) {
-	tx, err := r.db.Beginx()
+// คืน checkpoint เป็น nil หาก subscription ถูกล็อกโดย process อื่นหรือถูก pause อยู่
+func (r *eventSubscriptionRepository) ReadCheckpointAndLockSubscription(ctx context.Context, subscriptionName string) (*sqlx.Tx, *core.EventSubscriptionCheckpoint, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
 	}
@@ -59,10 +62,12 @@ FROM
     es_event_subscription
 WHERE
     subscription_name = $1
+AND
+    NOT paused
 FOR UPDATE
     SKIP LOCKED
 	`
-	rows, err := tx.Query(query, subscriptionName)
+	rows, err := tx.QueryContext(ctx, query, subscriptionName)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to read checkpoint: %w", err)
 	}
@@ -90,8 +95,9 @@ FOR UPDATE
 	return tx, nil, nil
 }
 
-// ReadEventsAfterCheckpoint implements core.EventStore.
-func (r *eventSubscriptionRepository) ReadEventsAfterCheckpoint(tx *sqlx.Tx, aggregateType string, lastTransactionID int64, lastEventID int64) ([]core.Event, error) {
+// ReadEventsAfterCheckpoint implements core.EventSubscriptionRepository.
+// อ่าน event ที่อยู่หลัง checkpoint ไม่เกิน limit รายการ
+func (r *eventSubscriptionRepository) ReadEventsAfterCheckpoint(ctx context.Context, tx *sqlx.Tx, aggregateType string, lastTransactionID int64, lastEventID int64, limit int) ([]core.Event, error) {
 	query := `
 SELECT
 		es_event.id,
@@ -113,62 +119,21 @@ AND
 		es_event.transaction_id < pg_snapshot_xmin(pg_current_snapshot())
 ORDER BY
     es_event.transaction_id, es_event.id
+LIMIT $4
 	`
 	var events []event
-	if err := tx.Select(&events, query, aggregateType, lastTransactionID, lastEventID); err != nil {
+	if err := tx.SelectContext(ctx, &events, query, aggregateType, lastTransactionID, lastEventID, limit); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
 		return nil, err
 	}
 
-	loadedEvents := make([]core.Event, 0, len(events))
-	for _, event := range events {
-		switch event.EventType {
-		case reflect.TypeOf(order.OrderCreatedEvent{}).Name():
-			eventData := order.OrderCreatedEvent{}
-			json.Unmarshal(event.EventData, &eventData)
-			loadedEvents = append(loadedEvents, core.Event{
-				ID:            event.ID,
-				TransactionID: event.TransactionID,
-				AggregateID:   event.AggregateID,
-				EventType:     event.EventType,
-				EventData:     eventData,
-				Version:       event.Version,
-				CreatedAt:     event.CreatedAt,
-			})
-
-		case reflect.TypeOf(order.OrderUpdatedEvent{}).Name():
-			eventData := order.OrderUpdatedEvent{}
-			json.Unmarshal(event.EventData, &eventData)
-			loadedEvents = append(loadedEvents, core.Event{
-				ID:            event.ID,
-				TransactionID: event.TransactionID,
-				AggregateID:   event.AggregateID,
-				EventType:     event.EventType,
-				EventData:     eventData,
-				Version:       event.Version,
-				CreatedAt:     event.CreatedAt,
-			})
-		case reflect.TypeOf(order.OrderItemAmountUpdatedEvent{}).Name():
-			eventData := order.OrderItemAmountUpdatedEvent{}
-			json.Unmarshal(event.EventData, &eventData)
-			loadedEvents = append(loadedEvents, core.Event{
-				ID:            event.ID,
-				TransactionID: event.TransactionID,
-				AggregateID:   event.AggregateID,
-				EventType:     event.EventType,
-				EventData:     eventData,
-				Version:       event.Version,
-				CreatedAt:     event.CreatedAt,
-			})
-		}
-	}
-	return loadedEvents, nil
+	return decodeEvents(r.registry, events)
 }
 
 // UpdateEventSubscription อัปเดต event subscription ด้วยข้อมูลล่าสุดที่ประมวลผล
-func (r *eventSubscriptionRepository) UpdateEventSubscription(tx *sqlx.Tx, subscriptionName string, lastTransactionID int64, lastEventID int64) (bool, error) {
+func (r *eventSubscriptionRepository) UpdateEventSubscription(ctx context.Context, tx *sqlx.Tx, subscriptionName string, lastTransactionID int64, lastEventID int64) (bool, error) {
 	query := `
 UPDATE
     es_event_subscription
@@ -178,7 +143,7 @@ SET
 WHERE
     subscription_name = $3
 	`
-	result, err := tx.Exec(query, lastTransactionID, lastEventID, subscriptionName)
+	result, err := tx.ExecContext(ctx, query, lastTransactionID, lastEventID, subscriptionName)
 	if err != nil {
 		return false, fmt.Errorf("failed to update event subscription: %w", err)
 	}
@@ -190,3 +155,319 @@ WHERE
 
 	return rowsAffected > 0, nil
 }
+
+// SaveDeadLetter เก็บ event ที่ประมวลผลไม่สำเร็จจนหมดจำนวนครั้งที่ทำซ้ำได้ เพื่อให้ subscription ทำงานต่อได้
+func (r *eventSubscriptionRepository) SaveDeadLetter(ctx context.Context, tx *sqlx.Tx, subscriptionName string, event core.Event, attempts int, reason string) error {
+	query := `
+INSERT INTO es_event_dead_letter (subscription_name, event_id, aggregate_id, version, event_type, attempts, error)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (subscription_name, event_id)
+    DO UPDATE SET
+        attempts = EXCLUDED.attempts,
+        error = EXCLUDED.error
+	`
+	if _, err := tx.ExecContext(ctx, query, subscriptionName, event.ID, event.AggregateID, event.Version, event.EventType, attempts, reason); err != nil {
+		return fmt.Errorf("failed to save dead letter: %w", err)
+	}
+	return nil
+}
+
+// ListDeadLetters คืน event ที่ค้างอยู่ใน dead letter ของ subscription เรียงตาม event ID
+func (r *eventSubscriptionRepository) ListDeadLetters(ctx context.Context, subscriptionName string) ([]core.EventDeadLetter, error) {
+	query := `
+SELECT
+    subscription_name,
+    event_id,
+    aggregate_id,
+    version,
+    event_type,
+    attempts,
+    error,
+    created_at
+FROM
+    es_event_dead_letter
+WHERE
+    subscription_name = $1
+ORDER BY
+    event_id
+	`
+	var deadLetters []struct {
+		SubscriptionName string    `db:"subscription_name"`
+		EventID          int64     `db:"event_id"`
+		AggregateID      uuid.UUID `db:"aggregate_id"`
+		Version          int       `db:"version"`
+		EventType        string    `db:"event_type"`
+		Attempts         int       `db:"attempts"`
+		Error            string    `db:"error"`
+		CreatedAt        time.Time `db:"created_at"`
+	}
+	if err := r.db.SelectContext(ctx, &deadLetters, query, subscriptionName); err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	result := make([]core.EventDeadLetter, 0, len(deadLetters))
+	for _, deadLetter := range deadLetters {
+		result = append(result, core.EventDeadLetter(deadLetter))
+	}
+	return result, nil
+}
+
+// ReadDeadLetterEvents อ่าน event ที่ค้างอยู่ใน dead letter ของ subscription ไม่เกิน limit รายการ
+// เรียงตามลำดับเดียวกับที่ subscription อ่าน event
+func (r *eventSubscriptionRepository) ReadDeadLetterEvents(ctx context.Context, tx *sqlx.Tx, subscriptionName string, limit int) ([]core.Event, error) {
+	query := `
+SELECT
+    es_event.id,
+    es_event.transaction_id,
+    es_event.aggregate_id,
+    es_event.event_type,
+    es_event.event_data,
+    es_event.version,
+    es_event.created_at
+FROM
+    es_event_dead_letter
+JOIN
+    es_event ON es_event.id = es_event_dead_letter.event_id
+WHERE
+    es_event_dead_letter.subscription_name = $1
+ORDER BY
+    es_event.transaction_id, es_event.id
+LIMIT $2
+	`
+	var events []event
+	if err := tx.SelectContext(ctx, &events, query, subscriptionName, limit); err != nil {
+		return nil, fmt.Errorf("failed to read dead letter events: %w", err)
+	}
+	return decodeEvents(r.registry, events)
+}
+
+// DeleteDeadLetter ลบ event ออกจาก dead letter ของ subscription หลังจากประมวลผลซ้ำสำเร็จ
+func (r *eventSubscriptionRepository) DeleteDeadLetter(ctx context.Context, tx *sqlx.Tx, subscriptionName string, eventID int64) error {
+	query := `
+DELETE FROM es_event_dead_letter
+WHERE subscription_name = $1
+    AND event_id = $2
+	`
+	if _, err := tx.ExecContext(ctx, query, subscriptionName, eventID); err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+	return nil
+}
+
+// GetSubscriptionInfo อ่าน checkpoint สถานะ pause และ lag ของ subscription เทียบกับ event ของ aggregateType
+func (r *eventSubscriptionRepository) GetSubscriptionInfo(ctx context.Context, subscriptionName string, aggregateType string) (*core.EventSubscriptionInfo, error) {
+	query := `
+SELECT
+    s.subscription_name,
+    s.last_transaction_id,
+    s.last_event_id,
+    s.paused,
+    count(e.id) AS lag_events,
+    COALESCE(EXTRACT(EPOCH FROM (now() - min(e.created_at))), 0) AS lag_seconds
+FROM
+    es_event_subscription s
+LEFT JOIN
+    (
+        SELECT
+            es_event.id,
+            es_event.transaction_id,
+            es_event.created_at
+        FROM
+            es_event
+        JOIN
+            es_aggregate ON es_aggregate.id = es_event.aggregate_id
+        WHERE
+            aggregate_type = $2
+    ) e ON (e.transaction_id, e.id) > (s.last_transaction_id, s.last_event_id)
+WHERE
+    s.subscription_name = $1
+GROUP BY
+    s.subscription_name
+	`
+	var row struct {
+		SubscriptionName  string  `db:"subscription_name"`
+		LastTransactionID int64   `db:"last_transaction_id"`
+		LastEventID       int64   `db:"last_event_id"`
+		Paused            bool    `db:"paused"`
+		LagEvents         int64   `db:"lag_events"`
+		LagSeconds        float64 `db:"lag_seconds"`
+	}
+	if err := r.db.GetContext(ctx, &row, query, subscriptionName, aggregateType); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, core.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to get subscription info: %w", err)
+	}
+
+	return &core.EventSubscriptionInfo{
+		SubscriptionName: row.SubscriptionName,
+		EventSubscriptionCheckpoint: core.EventSubscriptionCheckpoint{
+			LasttransactionID: row.LastTransactionID,
+			LastEventID:       row.LastEventID,
+		},
+		Paused:     row.Paused,
+		LagEvents:  row.LagEvents,
+		LagSeconds: row.LagSeconds,
+	}, nil
+}
+
+// SetSubscriptionPaused หยุดหรือให้ subscription กลับมาประมวลผล event
+func (r *eventSubscriptionRepository) SetSubscriptionPaused(ctx context.Context, subscriptionName string, paused bool) error {
+	query := `
+UPDATE
+    es_event_subscription
+SET
+    paused = $1
+WHERE
+    subscription_name = $2
+	`
+	result, err := r.db.ExecContext(ctx, query, paused, subscriptionName)
+	if err != nil {
+		return fmt.Errorf("failed to set subscription paused: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return core.ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+// ResetSubscriptionToEvent ย้าย checkpoint ไปก่อน eventID เพื่อให้ subscription ประมวลผลตั้งแต่ eventID อีกครั้ง
+// eventID ต้องเป็น event ของ aggregateType ที่ subscription ประมวลผล
+func (r *eventSubscriptionRepository) ResetSubscriptionToEvent(ctx context.Context, subscriptionName string, aggregateType string, eventID int64) error {
+	tx, err := r.lockSubscription(ctx, subscriptionName)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+SELECT
+    es_event.transaction_id,
+    es_event.id,
+    es_aggregate.aggregate_type
+FROM
+    es_event
+JOIN
+    es_aggregate ON es_aggregate.id = es_event.aggregate_id
+WHERE
+    es_event.id = $1
+	`
+	var checkpoint struct {
+		TransactionID int64  `db:"transaction_id"`
+		ID            int64  `db:"id"`
+		AggregateType string `db:"aggregate_type"`
+	}
+	if err := tx.GetContext(ctx, &checkpoint, query, eventID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return core.ErrEventNotFound
+		}
+		return err
+	}
+	if checkpoint.AggregateType != aggregateType {
+		return fmt.Errorf("%w: event %d is %s, subscription %s reads %s", core.ErrEventAggregateTypeMismatch, eventID, checkpoint.AggregateType, subscriptionName, aggregateType)
+	}
+
+	if _, err := r.UpdateEventSubscription(ctx, tx, subscriptionName, checkpoint.TransactionID, checkpoint.ID-1); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ResetSubscriptionToTime ย้าย checkpoint ไปก่อน event แรกของ aggregateType ที่เกิดตั้งแต่ at
+// หากไม่มี event หลังจาก at จะย้ายไปยัง event ล่าสุดเพื่อไม่ให้มีการประมวลผลซ้ำ
+func (r *eventSubscriptionRepository) ResetSubscriptionToTime(ctx context.Context, subscriptionName string, aggregateType string, at time.Time) error {
+	tx, err := r.lockSubscription(ctx, subscriptionName)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+SELECT
+    transaction_id,
+    id
+FROM
+    (
+        (
+            SELECT
+                es_event.transaction_id,
+                es_event.id - 1 AS id,
+                0 AS priority
+            FROM
+                es_event
+            JOIN
+                es_aggregate ON es_aggregate.id = es_event.aggregate_id
+            WHERE
+                aggregate_type = $1
+            AND
+                es_event.created_at >= $2
+            ORDER BY
+                es_event.transaction_id, es_event.id
+            LIMIT 1
+        )
+        UNION ALL
+        (
+            SELECT
+                es_event.transaction_id,
+                es_event.id,
+                1 AS priority
+            FROM
+                es_event
+            JOIN
+                es_aggregate ON es_aggregate.id = es_event.aggregate_id
+            WHERE
+                aggregate_type = $1
+            ORDER BY
+                es_event.transaction_id DESC, es_event.id DESC
+            LIMIT 1
+        )
+    ) checkpoints
+ORDER BY
+    priority
+LIMIT 1
+	`
+	var checkpoint struct {
+		TransactionID int64 `db:"transaction_id"`
+		ID            int64 `db:"id"`
+	}
+	if err := tx.GetContext(ctx, &checkpoint, query, aggregateType, at); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	if _, err := r.UpdateEventSubscription(ctx, tx, subscriptionName, checkpoint.TransactionID, checkpoint.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockSubscription เปิด transaction และล็อก subscription โดยรอจน batch ที่กำลังประมวลผลอยู่ commit
+func (r *eventSubscriptionRepository) lockSubscription(ctx context.Context, subscriptionName string) (*sqlx.Tx, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	query := `
+SELECT
+    subscription_name
+FROM
+    es_event_subscription
+WHERE
+    subscription_name = $1
+FOR UPDATE
+	`
+	var name string
+	if err := tx.GetContext(ctx, &name, query, subscriptionName); err != nil {
+		tx.Rollback()
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, core.ErrSubscriptionNotFound
+		}
+		return nil, fmt.Errorf("failed to lock subscription: %w", err)
+	}
+	return tx, nil
+}
@@ -0,0 +1,197 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/gofrs/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// outboxRelayLockKey คือ key ของ advisory lock ที่ relay ถือระหว่างส่ง message หนึ่ง batch
+const outboxRelayLockKey int64 = 0x6f7574626f78 // "outbox"
+
+type outboxRepository struct {
+	db *sqlx.DB
+}
+
+type outboxMessage struct {
+	ID            uuid.UUID       `db:"message_id"`
+	AggregateType string          `db:"aggregate_type"`
+	AggregateID   uuid.UUID       `db:"aggregate_id"`
+	Topic         string          `db:"topic"`
+	Key           string          `db:"message_key"`
+	EventType     string          `db:"event_type"`
+	Payload       json.RawMessage `db:"payload"`
+	Headers       json.RawMessage `db:"headers"`
+	CreatedAt     time.Time       `db:"created_at"`
+}
+
+// NewOutboxRepository ฟังก์ชันสำหรับสร้าง OutboxRepository ใหม่
+func NewOutboxRepository(db *sqlx.DB) core.OutboxRepository {
+	return &outboxRepository{
+		db: db,
+	}
+}
+
+// SaveTx implements core.OutboxRepository.
+func (r *outboxRepository) SaveTx(ctx context.Context, tx *sqlx.Tx, messages []core.OutboxMessage) error {
+	query := `
+INSERT INTO outbox (message_id, aggregate_type, aggregate_id, topic, message_key, event_type, payload, headers, created_at)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+ON CONFLICT (message_id)
+    DO NOTHING
+	`
+	for _, message := range messages {
+		headers, err := json.Marshal(message.Headers)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, query, message.ID, message.AggregateType, message.AggregateID, message.Topic, message.Key, message.EventType, message.Payload, headers, message.CreatedAt); err != nil {
+			return fmt.Errorf("failed to save outbox message: %w", err)
+		}
+	}
+	return nil
+}
+
+// LockUnpublished implements core.OutboxRepository.
+// advisory lock ของ transaction ทำให้มี relay เพียงตัวเดียวที่ส่ง message ในแต่ละช่วงเวลา
+// message ของ aggregate เดียวกันจึงถูกส่งตามลำดับ position แม้มี relay หลายตัว
+func (r *outboxRepository) LockUnpublished(ctx context.Context, limit int) (*sqlx.Tx, []core.OutboxMessage, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	lockQuery := `
+SELECT pg_try_advisory_xact_lock($1)
+	`
+	var locked bool
+	if err := tx.GetContext(ctx, &locked, lockQuery, outboxRelayLockKey); err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	if !locked {
+		return tx, nil, nil
+	}
+
+	query := `
+SELECT
+    message_id,
+    aggregate_type,
+    aggregate_id,
+    topic,
+    message_key,
+    event_type,
+    payload,
+    headers,
+    created_at
+FROM
+    outbox
+WHERE
+    published_at IS NULL
+ORDER BY
+    position
+LIMIT $1
+	`
+	var rows []outboxMessage
+	if err := tx.SelectContext(ctx, &rows, query, limit); err != nil {
+		tx.Rollback()
+		return nil, nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	messages := make([]core.OutboxMessage, 0, len(rows))
+	for _, row := range rows {
+		headers := map[string]string{}
+		if err := json.Unmarshal(row.Headers, &headers); err != nil {
+			tx.Rollback()
+			return nil, nil, fmt.Errorf("failed to decode headers of outbox message %s: %w", row.ID, err)
+		}
+		messages = append(messages, core.OutboxMessage{
+			ID:            row.ID,
+			AggregateType: row.AggregateType,
+			AggregateID:   row.AggregateID,
+			Topic:         row.Topic,
+			Key:           row.Key,
+			EventType:     row.EventType,
+			Payload:       row.Payload,
+			Headers:       headers,
+			CreatedAt:     row.CreatedAt,
+		})
+	}
+	return tx, messages, nil
+}
+
+// MarkPublished implements core.OutboxRepository.
+func (r *outboxRepository) MarkPublished(ctx context.Context, tx *sqlx.Tx, ids []uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `
+UPDATE
+    outbox
+SET
+    published_at = now()
+WHERE
+    message_id = ANY($1::uuid[])
+	`
+	messageIDs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		messageIDs = append(messageIDs, id.String())
+	}
+	if _, err := tx.ExecContext(ctx, query, pq.Array(messageIDs)); err != nil {
+		return fmt.Errorf("failed to mark outbox messages as published: %w", err)
+	}
+	return nil
+}
+
+// DeletePublishedBefore implements core.OutboxRepository.
+func (r *outboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+DELETE FROM outbox
+WHERE published_at < $1
+	`
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox messages: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetBackfillCutover implements core.OutboxRepository.
+func (r *outboxRepository) GetBackfillCutover(ctx context.Context) (int64, bool, error) {
+	query := `
+SELECT
+    cutover_event_id,
+    completed_at IS NOT NULL AS completed
+FROM
+    outbox_backfill
+	`
+	var backfill struct {
+		CutoverEventID int64 `db:"cutover_event_id"`
+		Completed      bool  `db:"completed"`
+	}
+	if err := r.db.GetContext(ctx, &backfill, query); err != nil {
+		return 0, false, fmt.Errorf("failed to read outbox backfill: %w", err)
+	}
+	return backfill.CutoverEventID, backfill.Completed, nil
+}
+
+// CompleteBackfill implements core.OutboxRepository.
+func (r *outboxRepository) CompleteBackfill(ctx context.Context) error {
+	query := `
+UPDATE
+    outbox_backfill
+SET
+    completed_at = now()
+WHERE
+    completed_at IS NULL
+	`
+	if _, err := r.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to complete outbox backfill: %w", err)
+	}
+	return nil
+}
@@ -1,9 +1,13 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
+	"github.com/gofrs/uuid"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -12,41 +16,126 @@ type queryOrderRepository struct {
 }
 
 // SaveOrder implements order.QueryOrderRepository.
-func (q *queryOrderRepository) SaveOrder(OrderAggregate *order.OrderAggregate) error {
-	tx, err := q.db.Beginx()
+func (q *queryOrderRepository) SaveOrder(ctx context.Context, OrderAggregate *order.OrderAggregate) error {
+	tx, err := q.db.BeginTxx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
 	defer tx.Rollback()
+	if err := q.SaveOrderTx(ctx, tx, OrderAggregate); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SaveOrderTx implements order.QueryOrderRepository.
+func (q *queryOrderRepository) SaveOrderTx(ctx context.Context, tx *sqlx.Tx, OrderAggregate *order.OrderAggregate) error {
 	orderItems, _ := json.Marshal(OrderAggregate.OrderItems)
 	query := `
-	INSERT INTO orders (id, version, name, order_items, is_submitted)
-	    VALUES ($1, $2, $3, $4, $5)
+	INSERT INTO orders (id, version, name, order_items, status, cancellation_reason)
+	    VALUES ($1, $2, $3, $4, $5, $6)
 	ON CONFLICT (id)
 	    DO UPDATE SET
-	        version = $2, name = $3, order_items = $4, is_submitted = $5, updated_at = NOW()
+	        version = $2, name = $3, order_items = $4, status = $5, cancellation_reason = $6, updated_at = NOW()
+	    WHERE
+	        orders.version < $2
 			`
-	_, err = tx.Exec(query,
-		OrderAggregate.ID, OrderAggregate.Version, OrderAggregate.Name, orderItems, OrderAggregate.IsSubmitted,
+	_, err := tx.ExecContext(ctx, query,
+		OrderAggregate.ID, OrderAggregate.Version, OrderAggregate.Name, orderItems, OrderAggregate.Status, OrderAggregate.CancellationReason,
 	)
-	if err != nil {
-		return err
+	return err
+}
+
+// GetOrderVersion implements order.QueryOrderRepository.
+func (q *queryOrderRepository) GetOrderVersion(ctx context.Context, id uuid.UUID) (int, error) {
+	var version int
+	if err := q.db.GetContext(ctx, &version, "SELECT version FROM orders WHERE id = $1", id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
 	}
-	return tx.Commit()
+	return version, nil
 }
 
 // GetOrders implements order.QueryOrderRepository.
-func (q *queryOrderRepository) GetOrders() ([]order.Order, error) {
-	var orders []order.Order
-	if err := q.db.Select(&orders, "SELECT * FROM orders"); err != nil {
+func (q *queryOrderRepository) GetOrders(ctx context.Context) ([]order.Order, error) {
+	query := `
+	SELECT
+	    id, version, name, order_items, status, cancellation_reason
+	FROM
+	    orders
+	ORDER BY
+	    created_at
+			`
+	var rows []orderRow
+	if err := q.db.SelectContext(ctx, &rows, query); err != nil {
 		return nil, err
 	}
+
+	orders := make([]order.Order, 0, len(rows))
+	for _, row := range rows {
+		o, err := row.toOrder()
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
 	return orders, nil
 }
 
+// GetOrder implements order.QueryOrderRepository.
+func (q *queryOrderRepository) GetOrder(ctx context.Context, id uuid.UUID) (*order.Order, error) {
+	query := `
+	SELECT
+	    id, version, name, order_items, status, cancellation_reason
+	FROM
+	    orders
+	WHERE
+	    id = $1
+			`
+	var row orderRow
+	if err := q.db.GetContext(ctx, &row, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, order.ErrOrderNotFound
+		}
+		return nil, err
+	}
+
+	o, err := row.toOrder()
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
 func NewQueryOrderRepository(db *sqlx.DB) order.QueryOrderRepository {
 	return &queryOrderRepository{
 		db: db,
 	}
 }
+
+type orderRow struct {
+	ID                 uuid.UUID       `db:"id"`
+	Version            int             `db:"version"`
+	Name               string          `db:"name"`
+	OrderItems         json.RawMessage `db:"order_items"`
+	Status             string          `db:"status"`
+	CancellationReason string          `db:"cancellation_reason"`
+}
+
+func (r orderRow) toOrder() (order.Order, error) {
+	var orderItems []order.OrderItem
+	if err := json.Unmarshal(r.OrderItems, &orderItems); err != nil {
+		return order.Order{}, err
+	}
+	return order.Order{
+		ID:                 r.ID,
+		Version:            r.Version,
+		Name:               r.Name,
+		OrderItems:         orderItems,
+		Status:             order.OrderStatus(r.Status),
+		CancellationReason: r.CancellationReason,
+	}, nil
+}
@@ -13,6 +13,8 @@ type CommandHandler interface {
 	CreateOrderHadler(c echo.Context) error
 	UpdatedOrderHandler(c echo.Context) error
 	UpdateOrderItemAmountHandler(c echo.Context) error
+	SubmitOrderHandler(c echo.Context) error
+	CancelOrderHandler(c echo.Context) error
 }
 
 type commandHandler struct {
@@ -20,8 +22,9 @@ type commandHandler struct {
 }
 
 type orderRequest struct {
-	Name       string `json:"name"`
-	OrderItems []struct {
+	ExpectedVersion *int   `json:"expected_version,omitempty"`
+	Name            string `json:"name"`
+	OrderItems      []struct {
 		ID     uuid.UUID `json:"id"`
 		Name   string    `json:"name"`
 		Amount int       `json:"amount"`
@@ -29,8 +32,13 @@ type orderRequest struct {
 }
 
 type updateOrderItemAmountRequest struct {
-	OrderItemID string `json:"order_item_id"`
-	Amount      int    `json:"amount"`
+	ExpectedVersion *int   `json:"expected_version,omitempty"`
+	OrderItemID     string `json:"order_item_id"`
+	Amount          int    `json:"amount"`
+}
+
+type cancelOrderRequest struct {
+	Reason string `json:"reason"`
 }
 
 // CreateOrderHadler implements CommandHandler.
@@ -50,16 +58,21 @@ func (h *commandHandler) CreateOrderHadler(c echo.Context) error {
 		})
 	}
 
-	if err := h.commandOrderUsecase.CreateOrder(orderRequest.Name, orderItems); err != nil {
+	version, err := h.commandOrderUsecase.CreateOrder(c.Request().Context(), orderRequest.Name, orderItems)
+	if err != nil {
 		return err
 	}
+	c.Response().Header().Set(headerETag, formatETag(version))
 
 	return c.JSON(http.StatusOK, orderRequest)
 }
 
 // UpdateOrderItemAmountHandler implements CommandHandler.
 func (h *commandHandler) UpdateOrderItemAmountHandler(c echo.Context) error {
-	id := uuid.FromStringOrNil(c.Param("id"))
+	id, err := parseOrderID(c)
+	if err != nil {
+		return err
+	}
 
 	updateOrderItemAmountRequest := updateOrderItemAmountRequest{}
 
@@ -67,15 +80,25 @@ func (h *commandHandler) UpdateOrderItemAmountHandler(c echo.Context) error {
 		return err
 	}
 
-	if err := h.commandOrderUsecase.UpdateOrderItemAmount(id, uuid.FromStringOrNil(updateOrderItemAmountRequest.OrderItemID), updateOrderItemAmountRequest.Amount); err != nil {
+	expectedVersion, err := parseExpectedVersion(c, updateOrderItemAmountRequest.ExpectedVersion)
+	if err != nil {
 		return err
 	}
+
+	version, err := h.commandOrderUsecase.UpdateOrderItemAmount(c.Request().Context(), id, expectedVersion, uuid.FromStringOrNil(updateOrderItemAmountRequest.OrderItemID), updateOrderItemAmountRequest.Amount)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(headerETag, formatETag(version))
 	return c.JSON(http.StatusOK, updateOrderItemAmountRequest)
 }
 
 // UpdatedOrderHandler implements CommandHandler.
 func (h *commandHandler) UpdatedOrderHandler(c echo.Context) error {
-	id := uuid.FromStringOrNil(c.Param("id"))
+	id, err := parseOrderID(c)
+	if err != nil {
+		return err
+	}
 	orderRequest := orderRequest{}
 	if err := c.Bind(&orderRequest); err != nil {
 		return err
@@ -89,13 +112,68 @@ func (h *commandHandler) UpdatedOrderHandler(c echo.Context) error {
 		})
 	}
 
-	if err := h.commandOrderUsecase.UpdatedOrder(id, orderRequest.Name, orderItems); err != nil {
+	expectedVersion, err := parseExpectedVersion(c, orderRequest.ExpectedVersion)
+	if err != nil {
 		return err
 	}
 
+	version, err := h.commandOrderUsecase.UpdatedOrder(c.Request().Context(), id, expectedVersion, orderRequest.Name, orderItems)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(headerETag, formatETag(version))
+
 	return c.JSON(http.StatusOK, orderRequest)
 }
 
+// SubmitOrderHandler implements CommandHandler.
+func (h *commandHandler) SubmitOrderHandler(c echo.Context) error {
+	id, err := parseOrderID(c)
+	if err != nil {
+		return err
+	}
+
+	version, err := h.commandOrderUsecase.SubmitOrder(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(headerETag, formatETag(version))
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"id": id,
+	})
+}
+
+// CancelOrderHandler implements CommandHandler.
+func (h *commandHandler) CancelOrderHandler(c echo.Context) error {
+	id, err := parseOrderID(c)
+	if err != nil {
+		return err
+	}
+
+	cancelOrderRequest := cancelOrderRequest{}
+	if err := c.Bind(&cancelOrderRequest); err != nil {
+		return err
+	}
+
+	version, err := h.commandOrderUsecase.CancelOrder(c.Request().Context(), id, cancelOrderRequest.Reason)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(headerETag, formatETag(version))
+
+	return c.JSON(http.StatusOK, cancelOrderRequest)
+}
+
+// parseOrderID อ่าน order id จาก path และคืน 400 หากไม่ใช่ UUID ที่ถูกต้อง
+func parseOrderID(c echo.Context) (uuid.UUID, error) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		return uuid.Nil, echo.NewHTTPError(http.StatusBadRequest, "invalid order id")
+	}
+	return id, nil
+}
+
 func NewCommandHandler(commandOrderUsecase application.CommandOrderUsecase) CommandHandler {
 	return &commandHandler{
 		commandOrderUsecase: commandOrderUsecase,
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// formatETag สร้าง strong ETag จาก version ของ order
+// command ใช้ version ของ aggregate ใน event store ที่ commit แล้ว ซึ่งไม่ขึ้นกับ read model ที่อาจยังตามไม่ทัน
+// ส่วน GET ใช้ version ของ read model ที่ตรงกับข้อมูลใน response
+func formatETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// formatOrdersETag สร้าง weak ETag จาก id และ version ของทุก order ในรายการ
+func formatOrdersETag(orders []order.Order) string {
+	h := fnv.New64a()
+	for _, o := range orders {
+		fmt.Fprintf(h, "%s:%d;", o.ID, o.Version)
+	}
+	return fmt.Sprintf(`W/"%x"`, h.Sum64())
+}
+
+// parseExpectedVersion อ่าน expected version จาก header If-Match หรือ expected_version ใน body
+// คืน nil หากไม่ได้ระบุ ซึ่งหมายถึงไม่ต้องตรวจสอบ version ส่วน If-Match: * ใช้ expected_version ใน body (ถ้ามี)
+// If-Match ต้องเป็น strong ETag เพียงค่าเดียว เพราะ command ตรวจสอบได้เพียง version เดียว
+func parseExpectedVersion(c echo.Context, bodyVersion *int) (*int, error) {
+	var tags []string
+	for _, value := range c.Request().Header.Values(headerIfMatch) {
+		for _, tag := range strings.Split(value, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	if len(tags) == 0 {
+		return bodyVersion, nil
+	}
+	if len(tags) > 1 {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "If-Match header must contain a single entity tag")
+	}
+	if tags[0] == "*" {
+		return bodyVersion, nil
+	}
+
+	tag, err := strconv.Unquote(tags[0])
+	if err != nil || !strings.HasPrefix(tags[0], `"`) {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid If-Match header")
+	}
+	version, err := strconv.Atoi(tag)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "invalid If-Match header")
+	}
+	if bodyVersion != nil && *bodyVersion != version {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "If-Match header does not match expected_version")
+	}
+	return &version, nil
+}
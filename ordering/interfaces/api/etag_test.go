@@ -0,0 +1,121 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestParseExpectedVersion(t *testing.T) {
+	version := func(v int) *int { return &v }
+
+	tests := []struct {
+		name        string
+		ifMatch     []string
+		bodyVersion *int
+		want        *int
+		wantStatus  int
+	}{
+		{
+			name: "no version",
+		},
+		{
+			name:        "body version without If-Match",
+			bodyVersion: version(3),
+			want:        version(3),
+		},
+		{
+			name:    "strong entity tag",
+			ifMatch: []string{`"3"`},
+			want:    version(3),
+		},
+		{
+			name:        "entity tag matching body version",
+			ifMatch:     []string{` "3" `},
+			bodyVersion: version(3),
+			want:        version(3),
+		},
+		{
+			name:        "entity tag conflicting with body version",
+			ifMatch:     []string{`"3"`},
+			bodyVersion: version(4),
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:    "any entity tag",
+			ifMatch: []string{"*"},
+		},
+		{
+			name:        "any entity tag keeps body version",
+			ifMatch:     []string{"*"},
+			bodyVersion: version(3),
+			want:        version(3),
+		},
+		{
+			name:       "weak entity tag",
+			ifMatch:    []string{`W/"3"`},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unquoted entity tag",
+			ifMatch:    []string{"3"},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "non numeric entity tag",
+			ifMatch:    []string{`"abc"`},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "multiple entity tags in one header",
+			ifMatch:    []string{`"3", "4"`},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "multiple If-Match headers",
+			ifMatch:    []string{`"3"`, `"4"`},
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "any entity tag with another entity tag",
+			ifMatch:    []string{`*, "3"`},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			for _, value := range tt.ifMatch {
+				req.Header.Add(headerIfMatch, value)
+			}
+			c := echo.New().NewContext(req, httptest.NewRecorder())
+
+			got, err := parseExpectedVersion(c, tt.bodyVersion)
+			if tt.wantStatus != 0 {
+				var httpErr *echo.HTTPError
+				if !errors.As(err, &httpErr) || httpErr.Code != tt.wantStatus {
+					t.Fatalf("parseExpectedVersion() error = %v, want status %d", err, tt.wantStatus)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseExpectedVersion() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseExpectedVersion() = %v, want %v", deref(got), deref(tt.want))
+			}
+		})
+	}
+}
+
+func deref(version *int) interface{} {
+	if version == nil {
+		return nil
+	}
+	return *version
+}
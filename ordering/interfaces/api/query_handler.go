@@ -9,6 +9,7 @@ import (
 
 type QueryHandler interface {
 	GetOrdersHandler(c echo.Context) error
+	GetOrderHandler(c echo.Context) error
 }
 
 type queryHandler struct {
@@ -17,16 +18,32 @@ type queryHandler struct {
 
 // GetOrdersHandler implements QueryHandler.
 func (q *queryHandler) GetOrdersHandler(c echo.Context) error {
-	orders, err := q.queryOrderUsecase.GetOrders()
+	orders, err := q.queryOrderUsecase.GetOrders(c.Request().Context())
 	if err != nil {
 		return err
 	}
 	resp := map[string]interface{}{
 		"orders": orders,
 	}
+	c.Response().Header().Set(headerETag, formatOrdersETag(orders))
 	return c.JSON(http.StatusOK, resp)
 }
 
+// GetOrderHandler implements QueryHandler.
+func (q *queryHandler) GetOrderHandler(c echo.Context) error {
+	id, err := parseOrderID(c)
+	if err != nil {
+		return err
+	}
+
+	order, err := q.queryOrderUsecase.GetOrder(c.Request().Context(), id)
+	if err != nil {
+		return err
+	}
+	c.Response().Header().Set(headerETag, formatETag(order.Version))
+	return c.JSON(http.StatusOK, order)
+}
+
 func NewQueryHandler(queryOrderUsecase application.QueryOrderUsecase) QueryHandler {
 	return &queryHandler{
 		queryOrderUsecase: queryOrderUsecase,
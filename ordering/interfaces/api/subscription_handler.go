@@ -0,0 +1,111 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/application"
+	"github.com/labstack/echo/v4"
+)
+
+type SubscriptionHandler interface {
+	GetSubscriptionsHandler(c echo.Context) error
+	PauseSubscriptionHandler(c echo.Context) error
+	ResumeSubscriptionHandler(c echo.Context) error
+	ResetSubscriptionHandler(c echo.Context) error
+	GetDeadLettersHandler(c echo.Context) error
+	RedriveDeadLettersHandler(c echo.Context) error
+}
+
+type subscriptionHandler struct {
+	subscriptionAdminUsecase application.SubscriptionAdminUsecase
+}
+
+// resetSubscriptionRequest ต้องระบุ event_id หรือ timestamp อย่างใดอย่างหนึ่ง
+type resetSubscriptionRequest struct {
+	EventID   *int64     `json:"event_id,omitempty"`
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// GetSubscriptionsHandler implements SubscriptionHandler.
+func (s *subscriptionHandler) GetSubscriptionsHandler(c echo.Context) error {
+	subscriptions, err := s.subscriptionAdminUsecase.ListSubscriptions(c.Request().Context())
+	if err != nil {
+		return err
+	}
+	resp := map[string]interface{}{
+		"subscriptions": subscriptions,
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// PauseSubscriptionHandler implements SubscriptionHandler.
+func (s *subscriptionHandler) PauseSubscriptionHandler(c echo.Context) error {
+	if err := s.subscriptionAdminUsecase.PauseSubscription(c.Request().Context(), c.Param("name")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ResumeSubscriptionHandler implements SubscriptionHandler.
+func (s *subscriptionHandler) ResumeSubscriptionHandler(c echo.Context) error {
+	if err := s.subscriptionAdminUsecase.ResumeSubscription(c.Request().Context(), c.Param("name")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ResetSubscriptionHandler implements SubscriptionHandler.
+func (s *subscriptionHandler) ResetSubscriptionHandler(c echo.Context) error {
+	resetSubscriptionRequest := resetSubscriptionRequest{}
+
+	if err := c.Bind(&resetSubscriptionRequest); err != nil {
+		return err
+	}
+
+	name := c.Param("name")
+	var err error
+	switch {
+	case resetSubscriptionRequest.EventID != nil && resetSubscriptionRequest.Timestamp == nil:
+		err = s.subscriptionAdminUsecase.ResetSubscriptionToEvent(c.Request().Context(), name, *resetSubscriptionRequest.EventID)
+	case resetSubscriptionRequest.Timestamp != nil && resetSubscriptionRequest.EventID == nil:
+		err = s.subscriptionAdminUsecase.ResetSubscriptionToTime(c.Request().Context(), name, *resetSubscriptionRequest.Timestamp)
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, "either event_id or timestamp is required")
+	}
+	if err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetDeadLettersHandler implements SubscriptionHandler.
+func (s *subscriptionHandler) GetDeadLettersHandler(c echo.Context) error {
+	deadLetters, err := s.subscriptionAdminUsecase.ListDeadLetters(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return err
+	}
+	resp := map[string]interface{}{
+		"dead_letters": deadLetters,
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+// RedriveDeadLettersHandler implements SubscriptionHandler.
+// event ที่สำเร็จก่อน event ที่ยังล้มเหลวถูกลบออกจาก dead letter แล้ว แม้ว่าจะคืน error
+func (s *subscriptionHandler) RedriveDeadLettersHandler(c echo.Context) error {
+	redriven, err := s.subscriptionAdminUsecase.RedriveDeadLetters(c.Request().Context(), c.Param("name"))
+	if err != nil {
+		return err
+	}
+	resp := map[string]interface{}{
+		"redriven": redriven,
+	}
+	return c.JSON(http.StatusOK, resp)
+}
+
+func NewSubscriptionHandler(subscriptionAdminUsecase application.SubscriptionAdminUsecase) SubscriptionHandler {
+	return &subscriptionHandler{
+		subscriptionAdminUsecase: subscriptionAdminUsecase,
+	}
+}
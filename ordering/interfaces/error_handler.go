@@ -0,0 +1,110 @@
+package interfaces
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/application"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/core"
+	"github.com/Bass-Peerapon/eventsource-demo/ordering/domain/order"
+	"github.com/labstack/echo/v4"
+)
+
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Problem คือ response body ตาม RFC 7807 พร้อม code สำหรับให้ frontend ใช้แสดงข้อความ
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+type errorMapping struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorMappings จับคู่ domain error กับ HTTP status และ error code
+var errorMappings = []errorMapping{
+	{order.ErrOrderNotFound, http.StatusNotFound, "ORDER_NOT_FOUND"},
+	{order.ErrItemNotFound, http.StatusNotFound, "ITEM_NOT_FOUND"},
+	{core.ErrAggregateNotFound, http.StatusNotFound, "AGGREGATE_NOT_FOUND"},
+	{core.ErrSubscriptionNotFound, http.StatusNotFound, "SUBSCRIPTION_NOT_FOUND"},
+	{core.ErrEventNotFound, http.StatusNotFound, "EVENT_NOT_FOUND"},
+	{core.ErrEventAggregateTypeMismatch, http.StatusConflict, "EVENT_AGGREGATE_TYPE_MISMATCH"},
+	{core.ErrSubscriptionLocked, http.StatusConflict, "SUBSCRIPTION_LOCKED"},
+	{order.ErrOrderIsSubmitted, http.StatusConflict, "ORDER_IS_SUBMITTED"},
+	{order.ErrOrderIsCancelled, http.StatusConflict, "ORDER_IS_CANCELLED"},
+	{order.ErrOrderIsCompleted, http.StatusConflict, "ORDER_IS_COMPLETED"},
+	{order.ErrInvalidOrderStatus, http.StatusConflict, "INVALID_ORDER_STATUS"},
+	{core.ErrAggregateOutdated, http.StatusConflict, "AGGREGATE_OUTDATED"},
+	{application.ErrConcurrencyConflict, http.StatusConflict, "CONCURRENCY_CONFLICT"},
+	{application.ErrExpectedVersionMismatch, http.StatusPreconditionFailed, "VERSION_MISMATCH"},
+	{order.ErrItemAmountLessThanZero, http.StatusUnprocessableEntity, "ITEM_AMOUNT_LESS_THAN_ZERO"},
+	{order.ErrItemAmountNotPositive, http.StatusUnprocessableEntity, "ITEM_AMOUNT_NOT_POSITIVE"},
+	{order.ErrOrderHasNoItems, http.StatusUnprocessableEntity, "ORDER_HAS_NO_ITEMS"},
+	{order.ErrCancellationReasonIsEmpty, http.StatusUnprocessableEntity, "CANCELLATION_REASON_IS_EMPTY"},
+}
+
+// HTTPErrorHandler แปลง error ที่คืนจาก handler เป็น application/problem+json
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	problem := newProblem(err)
+	problem.Instance = c.Request().URL.Path
+	if problem.Status == http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		err = c.NoContent(problem.Status)
+	} else {
+		err = c.JSON(problem.Status, problem)
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+func newProblem(err error) Problem {
+	for _, mapping := range errorMappings {
+		if errors.Is(err, mapping.err) {
+			return Problem{
+				Type:   "about:blank",
+				Title:  http.StatusText(mapping.status),
+				Status: mapping.status,
+				Detail: err.Error(),
+				Code:   mapping.code,
+			}
+		}
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		problem := Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(httpErr.Code),
+			Status: httpErr.Code,
+			Code:   strings.ToUpper(strings.ReplaceAll(http.StatusText(httpErr.Code), " ", "_")),
+		}
+		if message, ok := httpErr.Message.(string); ok {
+			problem.Detail = message
+		}
+		return problem
+	}
+
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+		Code:   "INTERNAL_SERVER_ERROR",
+	}
+}
@@ -1,8 +1,11 @@
 package interfaces
 
 import (
+	"crypto/subtle"
+
 	"github.com/Bass-Peerapon/eventsource-demo/ordering/interfaces/api"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
 type Route struct {
@@ -15,12 +18,32 @@ func NewRoute(e *echo.Echo) *Route {
 	}
 }
 
+func (r *Route) RegisterHTTPErrorHandler() {
+	r.e.HTTPErrorHandler = HTTPErrorHandler
+}
+
 func (r *Route) RegisterCommandOrderHandler(h api.CommandHandler) {
 	r.e.POST("/orders", h.CreateOrderHadler)
 	r.e.PUT("/orders/:id", h.UpdatedOrderHandler)
 	r.e.PUT("/orders/:id/items/:item_id", h.UpdateOrderItemAmountHandler)
+	r.e.POST("/orders/:id/submit", h.SubmitOrderHandler)
+	r.e.POST("/orders/:id/cancel", h.CancelOrderHandler)
 }
 
 func (r *Route) RegisterQueryOrderHandler(h api.QueryHandler) {
 	r.e.GET("/orders", h.GetOrdersHandler)
+	r.e.GET("/orders/:id", h.GetOrderHandler)
+}
+
+// RegisterSubscriptionHandler ลงทะเบียน admin API ที่ต้องส่ง header Authorization: Bearer <adminToken>
+func (r *Route) RegisterSubscriptionHandler(h api.SubscriptionHandler, adminToken string) {
+	admin := r.e.Group("/admin", middleware.KeyAuth(func(key string, c echo.Context) (bool, error) {
+		return subtle.ConstantTimeCompare([]byte(key), []byte(adminToken)) == 1, nil
+	}))
+	admin.GET("/subscriptions", h.GetSubscriptionsHandler)
+	admin.POST("/subscriptions/:name/pause", h.PauseSubscriptionHandler)
+	admin.POST("/subscriptions/:name/resume", h.ResumeSubscriptionHandler)
+	admin.POST("/subscriptions/:name/reset", h.ResetSubscriptionHandler)
+	admin.GET("/subscriptions/:name/dead-letters", h.GetDeadLettersHandler)
+	admin.POST("/subscriptions/:name/dead-letters/redrive", h.RedriveDeadLettersHandler)
 }
@@ -0,0 +1 @@
+DROP TABLE es_event_dead_letter;
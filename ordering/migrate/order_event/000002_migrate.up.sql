@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS es_event_dead_letter (
+  subscription_name  TEXT       NOT NULL,
+  event_id           BIGINT     NOT NULL REFERENCES es_event (id),
+  aggregate_id       UUID       NOT NULL,
+  version            INTEGER    NOT NULL,
+  event_type         TEXT       NOT NULL,
+  attempts           INTEGER    NOT NULL,
+  error              TEXT       NOT NULL,
+  created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+  PRIMARY KEY (subscription_name, event_id)
+);
@@ -0,0 +1,4 @@
+ALTER TABLE es_event ALTER COLUMN created_at TYPE TIMESTAMP
+    USING created_at AT TIME ZONE COALESCE(NULLIF(current_setting('ordering.legacy_time_zone', true), ''), 'UTC');
+
+ALTER TABLE es_event_subscription DROP COLUMN IF EXISTS paused;
@@ -0,0 +1,8 @@
+ALTER TABLE es_event_subscription ADD COLUMN IF NOT EXISTS paused BOOLEAN NOT NULL DEFAULT false;
+
+-- created_at ของ event เดิมถูกบันทึกเป็น TIMESTAMP ตามเวลา local ของ ordering service ที่บันทึก event
+-- จึงต้องระบุ time zone ของ service ผ่าน parameter ordering.legacy_time_zone (ค่าเริ่มต้น UTC) ก่อน migrate เช่น
+--   ALTER DATABASE "order-event" SET ordering.legacy_time_zone = 'Asia/Bangkok';
+-- หรือเพิ่ม options=-c%20ordering.legacy_time_zone%3DAsia/Bangkok ใน ORDER_EVENT_STORE
+ALTER TABLE es_event ALTER COLUMN created_at TYPE TIMESTAMPTZ
+    USING created_at AT TIME ZONE COALESCE(NULLIF(current_setting('ordering.legacy_time_zone', true), ''), 'UTC');
@@ -0,0 +1,2 @@
+DROP TABLE outbox_backfill;
+DROP TABLE outbox;
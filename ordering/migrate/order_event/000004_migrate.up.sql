@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS outbox (
+  position        BIGSERIAL    PRIMARY KEY,
+  message_id      UUID         NOT NULL UNIQUE,
+  aggregate_type  TEXT         NOT NULL,
+  aggregate_id    UUID         NOT NULL,
+  topic           TEXT         NOT NULL,
+  message_key     TEXT         NOT NULL,
+  event_type      TEXT         NOT NULL,
+  payload         JSONB        NOT NULL,
+  headers         JSONB        NOT NULL DEFAULT '{}',
+  created_at      TIMESTAMPTZ  NOT NULL,
+  published_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS IDX_OUTBOX_UNPUBLISHED ON outbox (position) WHERE published_at IS NULL;
+CREATE INDEX IF NOT EXISTS IDX_OUTBOX_PUBLISHED_AT ON outbox (published_at) WHERE published_at IS NOT NULL;
+
+-- event ที่มี id ไม่เกิน cutover_event_id ถูกบันทึกก่อนมี outbox และต้องถูก backfill ด้วย cmd/backfill_outbox เพียงครั้งเดียว
+CREATE TABLE IF NOT EXISTS outbox_backfill (
+  id                BOOLEAN      PRIMARY KEY DEFAULT true CHECK (id),
+  cutover_event_id  BIGINT       NOT NULL,
+  completed_at      TIMESTAMPTZ
+);
+
+INSERT INTO outbox_backfill (cutover_event_id)
+SELECT COALESCE(max(id), 0) FROM es_event
+ON CONFLICT (id) DO NOTHING;
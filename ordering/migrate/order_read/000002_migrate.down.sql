@@ -0,0 +1,6 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS is_submitted BOOLEAN NOT NULL DEFAULT false;
+
+UPDATE orders SET is_submitted = true WHERE status <> 'DRAFT';
+
+ALTER TABLE orders DROP COLUMN IF EXISTS cancellation_reason;
+ALTER TABLE orders DROP COLUMN IF EXISTS status;
@@ -0,0 +1,6 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'DRAFT';
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS cancellation_reason TEXT NOT NULL DEFAULT '';
+
+UPDATE orders SET status = 'SUBMITTED' WHERE is_submitted;
+
+ALTER TABLE orders DROP COLUMN IF EXISTS is_submitted;